@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+type Issue struct {
+	Id          string  `json:"id"`
+	Summary     string  `json:"summary"`
+	Description string  `json:"description"`
+	Project     Project `json:"project"`
+	Assignee    string  `json:"assignee"`
+	State       string  `json:"state"`
+	Estimation  int     `json:"estimation"` // minutes
+	SpentTime   int     `json:"spent_time"` // minutes
+}
+
+type Project struct {
+	ShortName string `json:"short_name"`
+	Name      string `json:"name"`
+}
+
+// ProjectKey is how per project settings are keyed, e.g. rounding and
+// toggl projects. Config keys come lower cased, short names are upper case.
+func ProjectKey(shortName string) string {
+	return strings.ToUpper(strings.TrimSpace(shortName))
+}
+
+type IssueWorkLog struct {
+	EntryId     string
+	IssueId     string
+	Title       string
+	Description string
+	Type        string
+	Duration    int
+	Date        time.Time
+}
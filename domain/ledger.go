@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const LedgerDateFormat = "2006-01-02"
+
+// WorkLogLedgerEntry remembers which YouTrack work item was created for
+// a time entry on a given day so that it is never logged twice.
+type WorkLogLedgerEntry struct {
+	EntryId    string `json:"entry_id"`
+	Date       string `json:"date"`
+	IssueId    string `json:"issue_id"`
+	WorkItemId string `json:"work_item_id"`
+	// SinkItemIds are ids of copies in work log sinks, by sink name
+	SinkItemIds map[string]string `json:"sink_item_ids,omitempty"`
+	Duration    int               `json:"duration"`
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	SyncedAt    time.Time         `json:"synced_at"`
+}
+
+func LedgerKey(entryId string, date time.Time, issueId string) string {
+	return fmt.Sprintf("%s|%s|%s", entryId, date.Format(LedgerDateFormat), issueId)
+}
+
+func (e WorkLogLedgerEntry) Key() string {
+	return fmt.Sprintf("%s|%s|%s", e.EntryId, e.Date, e.IssueId)
+}
+
+// Synced tells if work item exists, entry without it only remembers copies
+// left in work log sinks by failed compensation
+func (e WorkLogLedgerEntry) Synced() bool {
+	return e.WorkItemId != ""
+}
+
+// Matches tells if work log would send exactly what was already synced.
+func (e WorkLogLedgerEntry) Matches(workLog IssueWorkLog) bool {
+	return e.Duration == workLog.Duration &&
+		e.Type == workLog.Type &&
+		e.Description == workLog.Description
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	RoundNone    = "none"
+	RoundUp      = "up"
+	RoundDown    = "down"
+	RoundNearest = "nearest"
+)
+
+// RoundingPolicy rounds durations to multiples of Increment minutes,
+// anything shorter than Minimum is logged as Minimum
+type RoundingPolicy struct {
+	Mode      string
+	Increment int64
+	Minimum   int64
+}
+
+// Round returns rounded minutes, entries without any time stay without it
+func (p RoundingPolicy) Round(minutes int64) int64 {
+	if minutes <= 0 {
+		return minutes
+	}
+
+	rounded := minutes
+
+	if p.Increment > 0 {
+		switch p.Mode {
+		case RoundUp:
+			rounded = (minutes + p.Increment - 1) / p.Increment * p.Increment
+		case RoundDown:
+			rounded = minutes / p.Increment * p.Increment
+		case RoundNearest:
+			rounded = (minutes + p.Increment/2) / p.Increment * p.Increment
+		}
+	}
+
+	if rounded < p.Minimum {
+		rounded = p.Minimum
+	}
+
+	return rounded
+}
+
+// String describes policy, e.g. up 15m, min 30m
+func (p RoundingPolicy) String() string {
+	var parts []string
+
+	if p.Mode != "" && p.Mode != RoundNone && p.Increment > 0 {
+		parts = append(parts, fmt.Sprintf("%s %dm", p.Mode, p.Increment))
+	}
+
+	if p.Minimum > 0 {
+		parts = append(parts, fmt.Sprintf("min %dm", p.Minimum))
+	}
+
+	if len(parts) == 0 {
+		return RoundNone
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// EntryRounding tells how duration of the entry was rounded
+type EntryRounding struct {
+	Raw    int64  `json:"raw"` // minutes before rounding
+	Policy string `json:"policy"`
+	Capped bool   `json:"capped,omitempty"` // cut to fit daily cap
+}
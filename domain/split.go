@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SplitEven       = "even"
+	SplitAnnotation = "annotation" // issue got exactly the annotated duration
+	SplitWeight     = "weight"
+)
+
+// EntrySplit describes the part of a time entry that went to one issue
+type EntrySplit struct {
+	Total  int64   `json:"total"` // minutes of the whole entry
+	Rule   string  `json:"rule"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// annotations like ABC-1:30m or ABC-1:1h30m fix the duration of an issue,
+// ABC-1:2 gives it weight 2 while the rest of issues have weight 1
+var splitAnnotationRegex = regexp.MustCompile(`\b([A-Za-z][A-Za-z0-9_]*-\d+)\s*:\s*(\d+h\d+m|\d+h|\d+m|\d+(?:\.\d+)?)\b`)
+
+type splitAnnotation struct {
+	duration time.Duration
+	weight   float64
+}
+
+// SplitEntry splits entry across issue ids and issues annotated in its
+// description. Only issue ids and known ones can be annotated, so that e.g.
+// UTF-8: 2 is not taken for an issue. Annotated durations are given as they
+// are, or scaled down to the entry when they add up to more, the rest of the
+// time is split by weights. Entry is returned as it is when there is only one
+// issue to log to.
+func SplitEntry(entry TimeEntry, issueIds []string, known []string) []TimeEntry {
+	annotations := map[string]splitAnnotation{}
+
+	for _, match := range splitAnnotationRegex.FindAllStringSubmatch(entry.Description, -1) {
+		annotation, ok := parseSplitAnnotation(match[2])
+
+		if !ok || !(containsIssueId(issueIds, match[1]) || containsIssueId(known, match[1])) {
+			continue
+		}
+
+		if _, ok := annotations[match[1]]; !ok && !containsIssueId(issueIds, match[1]) {
+			issueIds = append(issueIds, match[1])
+		}
+
+		annotations[match[1]] = annotation
+	}
+
+	if len(issueIds) < 2 {
+		return []TimeEntry{entry}
+	}
+
+	var annotated int64
+	totalWeight := 0.0
+	weighted := false
+
+	for _, issueId := range issueIds {
+		annotation, ok := annotations[issueId]
+
+		switch {
+		case ok && annotation.duration > 0:
+			annotated += int64(annotation.duration.Minutes())
+		case ok:
+			totalWeight += annotation.weight
+			weighted = true
+		default:
+			totalWeight += 1
+		}
+	}
+
+	remaining := entry.Duration - annotated
+
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	parts := make([]TimeEntry, len(issueIds))
+	var fixed, shared int64
+	var fixedParts, sharedParts []int
+
+	for index, issueId := range issueIds {
+		part := entry
+		part.IssueId = issueId
+		part.Split = &EntrySplit{Total: entry.Duration, Rule: SplitEven}
+
+		annotation, ok := annotations[issueId]
+
+		switch {
+		case ok && annotation.duration > 0:
+			part.Duration = int64(annotation.duration.Minutes())
+			part.Split.Rule = SplitAnnotation
+
+			if annotated > entry.Duration {
+				part.Duration = part.Duration * entry.Duration / annotated
+			}
+
+			fixed += part.Duration
+			fixedParts = append(fixedParts, index)
+		default:
+			weight := 1.0
+
+			if ok {
+				weight = annotation.weight
+			}
+
+			if weighted {
+				part.Split.Rule = SplitWeight
+				part.Split.Weight = weight
+			}
+
+			if totalWeight > 0 {
+				part.Duration = int64(float64(remaining) * weight / totalWeight)
+			}
+
+			shared += part.Duration
+			sharedParts = append(sharedParts, index)
+		}
+
+		parts[index] = part
+	}
+
+	// minutes lost to rounding down go to first issues sharing the time
+	for i := 0; shared < remaining && len(sharedParts) > 0; i++ {
+		parts[sharedParts[i%len(sharedParts)]].Duration++
+		shared++
+	}
+
+	// same for annotated issues when they were scaled down
+	for i := 0; annotated > entry.Duration && fixed < entry.Duration; i++ {
+		parts[fixedParts[i%len(fixedParts)]].Duration++
+		fixed++
+	}
+
+	return parts
+}
+
+func parseSplitAnnotation(value string) (splitAnnotation, bool) {
+	if strings.HasSuffix(value, "h") || strings.HasSuffix(value, "m") {
+		duration, err := time.ParseDuration(value)
+		return splitAnnotation{duration: duration}, err == nil && duration > 0
+	}
+
+	weight, err := strconv.ParseFloat(value, 64)
+
+	return splitAnnotation{weight: weight}, err == nil && weight > 0
+}
+
+func containsIssueId(issueIds []string, issueId string) bool {
+	for _, id := range issueIds {
+		if id == issueId {
+			return true
+		}
+	}
+
+	return false
+}
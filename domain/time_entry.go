@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+)
+
+// TimeEntry is time spent on something, as reported by one of time sources.
+type TimeEntry struct {
+	// Id identifies entry across all sources, ledger remembers synced entries by it
+	Id         string `json:"id"`
+	Source     string `json:"source"`
+	ExternalId string `json:"external_id"`
+	Title      string `json:"title"`
+	// IssueId is set by sources that know the issue themselves, otherwise
+	// it is extracted from the title
+	IssueId     string    `json:"issue_id,omitempty"`
+	Description string    `json:"description"`
+	WorkType    string    `json:"worktype,omitempty"` // suggested by the source, e.g. Meeting
+	Duration    int64     `json:"duration"`           // minutes
+	Date        time.Time `json:"date"`               // day the time is logged on
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Links       []string  `json:"links"`
+	Members     []string  `json:"members"`
+	Labels      []string  `json:"labels,omitempty"`
+	List        string    `json:"list,omitempty"` // where the entry is now, e.g. trello list
+	// MappedBy names issue mapping rule which gave the issue id
+	MappedBy string `json:"mapped_by,omitempty"`
+	// Split is set on parts of an entry split across several issues
+	Split *EntrySplit `json:"split,omitempty"`
+	// Rounding is set when duration was rounded, it keeps the raw one
+	Rounding *EntryRounding `json:"rounding,omitempty"`
+}
+
+// TimeEntryFilter narrows down which entries and which of their time is
+// returned. Zero value of any field means no restriction. Each source
+// applies fields that make sense for it and ignores the rest.
+type TimeEntryFilter struct {
+	Lists         []string // time spent in these lists is counted, doing list by default
+	IncludeClosed bool
+	Labels        []string // entry needs at least one of the labels, by name
+	Members       []string // entry needs at least one of the members, by id or username
+	DueAfter      time.Time
+	DueBefore     time.Time
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+)
+
+// TimeWindow is a half open interval [From, To).
+type TimeWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// NewDaysWindow covers whole days from first to last day, both included,
+// with day boundaries taken in given location.
+func NewDaysWindow(firstDay, lastDay time.Time, location *time.Location) TimeWindow {
+	return TimeWindow{
+		From: StartOfDay(firstDay, location),
+		To:   StartOfDay(lastDay, location).AddDate(0, 0, 1),
+	}
+}
+
+// Clip returns part of [start, end) that falls into the window.
+func (w TimeWindow) Clip(start, end time.Time) (time.Time, time.Time, bool) {
+	if start.Before(w.From) {
+		start = w.From
+	}
+
+	if end.After(w.To) {
+		end = w.To
+	}
+
+	return start, end, start.Before(end)
+}
+
+func StartOfDay(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+type DayDuration struct {
+	Day      time.Time
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+}
+
+// SplitByDay cuts [start, end) on midnights of given location.
+func SplitByDay(start, end time.Time, location *time.Location) []DayDuration {
+	var days []DayDuration
+
+	for start.Before(end) {
+		day := StartOfDay(start, location)
+		nextDay := day.AddDate(0, 0, 1)
+
+		partEnd := end
+		if nextDay.Before(end) {
+			partEnd = nextDay
+		}
+
+		days = append(days, DayDuration{
+			Day:      day,
+			Start:    start,
+			End:      partEnd,
+			Duration: partEnd.Sub(start),
+		})
+
+		start = partEnd
+	}
+
+	return days
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/interfaces"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// app is everything web and cli share, built from config
+type app struct {
+	timeLogger      *usecases.TimeLoggerInteractor
+	issueRepository *interfaces.CachingIssueRepository
+	ledger          *interfaces.BoltWorkLogLedger // nil until opened
+	issueRules      *interfaces.IssueRules        // nil without rules file
+	location        *time.Location
+	defaultFilter   domain.TimeEntryFilter
+}
+
+func readConfig() error {
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("Cannot read config file: %s", err)
+	}
+
+	return nil
+}
+
+// newApp builds everything from config without opening the ledger, so
+// config can be checked without touching it
+func newApp() (*app, error) {
+	trelloApikey := viper.GetString("trello-api-key")
+	trelloApiToken := viper.GetString("trello-api-token")
+	trelloBoardId := viper.GetString("trello-board-id")
+	trelloDoingListName := viper.GetString("trello-doing-list-name")
+	trelloWorkers := viper.GetInt("trello-workers")
+	trelloMember := viper.GetString("trello-member")
+
+	location, err := time.LoadLocation(viper.GetString("timezone"))
+
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load timezone: %s", err)
+	}
+
+	defaultFilter, err := timeEntryFilter(location)
+
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read filter: %s", err)
+	}
+
+	youtrackApiKey := viper.GetString("youtrack-api-key")
+	youtrackBaseUrl := viper.GetString("youtrack-base-url")
+	youtrackCacheTtl := viper.GetDuration("youtrack-cache-ttl")
+	youtrackWorkers := viper.GetInt("youtrack-workers")
+
+	jiraBaseUrl := viper.GetString("jira-base-url")
+	jiraUser := viper.GetString("jira-user")
+	jiraToken := viper.GetString("jira-token")
+	jiraProjectKeys := viper.GetStringSlice("jira-project-keys")
+
+	togglApiToken := viper.GetString("toggl-api-token")
+	togglWorkspaceId := viper.GetInt("toggl-workspace-id")
+	togglRequired := viper.GetBool("toggl-required")
+	togglProjects, err := togglProjects()
+
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read toggl projects: %s", err)
+	}
+
+	rounding, err := durationRounding()
+
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read rounding: %s", err)
+	}
+
+	gitRepositories := viper.GetStringSlice("git-repositories")
+	gitAuthors := viper.GetStringSlice("git-authors")
+	gitSessionTimeout := viper.GetDuration("git-session-timeout")
+	gitFirstCommit := viper.GetDuration("git-first-commit")
+
+	icsCalendar := viper.GetString("ics-calendar")
+	icsDefaultIssue := viper.GetString("ics-default-issue")
+	icsAttendee := viper.GetString("ics-attendee")
+
+	pullRequestsProvider := viper.GetString("pull-requests-provider")
+	pullRequestsBaseUrl := viper.GetString("pull-requests-base-url")
+	pullRequestsToken := viper.GetString("pull-requests-token")
+	pullRequestsRepositories := viper.GetStringSlice("pull-requests-repositories")
+	pullRequestsUser := viper.GetString("pull-requests-user")
+	pullRequestsSessionTimeout := viper.GetDuration("pull-requests-session-timeout")
+	pullRequestsFirstEvent := viper.GetDuration("pull-requests-first-event")
+
+	issueBareKeys := viper.GetBool("issue-bare-keys")
+	issueProjects := viper.GetStringSlice("issue-projects")
+	issueProjectsFromYouTrack := viper.GetBool("issue-projects-from-youtrack")
+	issueRulesFile := viper.GetString("issue-rules-file")
+
+	heartbeatsFiles := viper.GetStringSlice("heartbeats-files")
+	heartbeatsIdleGap := viper.GetDuration("heartbeats-idle-gap")
+
+	youtrackClient := interfaces.NewYouTrackClient(
+		youtrackBaseUrl,
+		youtrackApiKey,
+	)
+
+	var projectLister interfaces.ProjectLister
+
+	if issueProjectsFromYouTrack {
+		projectLister = youtrackClient
+	}
+
+	// jira projects are known from config, youtrack ones are listed
+	extractor := interfaces.NewIssueKeyExtractor(
+		issueBareKeys,
+		append(issueProjects, jiraProjectKeys...),
+		projectLister,
+		youtrackCacheTtl,
+	)
+
+	trelloSource := interfaces.NewTrelloAdlioClient(
+		trelloApikey,
+		trelloApiToken,
+		trelloBoardId,
+		trelloDoingListName,
+		location,
+		trelloWorkers,
+		trelloMember,
+	)
+
+	timeSources := []usecases.TimeSource{trelloSource}
+
+	if len(gitRepositories) > 0 {
+		timeSources = append(timeSources, interfaces.NewGitLogSource(
+			gitRepositories,
+			gitAuthors,
+			gitSessionTimeout,
+			gitFirstCommit,
+			location,
+			extractor,
+		))
+	}
+
+	if icsCalendar != "" {
+		timeSources = append(timeSources, interfaces.NewICalendarSource(
+			icsCalendar,
+			location,
+			extractor,
+			icsDefaultIssue,
+			icsAttendee,
+		))
+	}
+
+	if pullRequestsProvider != "" {
+		pullRequestSource, err := interfaces.NewPullRequestSource(
+			pullRequestsProvider,
+			pullRequestsBaseUrl,
+			pullRequestsToken,
+			pullRequestsRepositories,
+			pullRequestsUser,
+			pullRequestsSessionTimeout,
+			pullRequestsFirstEvent,
+			location,
+			extractor,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create pull request source: %s", err)
+		}
+
+		timeSources = append(timeSources, pullRequestSource)
+	}
+
+	if len(heartbeatsFiles) > 0 {
+		timeSources = append(timeSources, interfaces.NewHeartbeatSource(
+			heartbeatsFiles,
+			heartbeatsIdleGap,
+			location,
+			extractor,
+		))
+	}
+
+	issueRouter := interfaces.NewIssueRepositoryRouter(youtrackClient)
+
+	if len(jiraProjectKeys) > 0 {
+		issueRouter.Route(interfaces.NewJiraClient(jiraBaseUrl, jiraUser, jiraToken), jiraProjectKeys...)
+	}
+
+	issueRepository := interfaces.NewCachingIssueRepository(issueRouter, youtrackCacheTtl)
+
+	var issueRules *interfaces.IssueRules
+
+	if issueRulesFile != "" {
+		if issueRules, err = interfaces.LoadIssueRules(issueRulesFile); err != nil {
+			return nil, fmt.Errorf("Cannot read issue rules: %s", err)
+		}
+	}
+
+	var destinations []usecases.Destination
+
+	if togglApiToken != "" {
+		destinations = append(destinations, usecases.Destination{
+			Sink:     interfaces.NewTogglClient(togglApiToken, togglWorkspaceId, togglProjects),
+			Required: togglRequired,
+		})
+	}
+
+	timeLoggerInteractor := &usecases.TimeLoggerInteractor{
+		IssueRepository:    issueRepository,
+		TimeSources:        timeSources,
+		IssueIdExtractor:   extractor,
+		Destinations:       destinations,
+		IssueLookupWorkers: youtrackWorkers,
+		Rounding:           rounding,
+	}
+
+	// nil rules would be a non nil mapper
+	if issueRules != nil {
+		timeLoggerInteractor.IssueMapper = issueRules
+	}
+
+	return &app{
+		timeLogger:      timeLoggerInteractor,
+		issueRepository: issueRepository,
+		issueRules:      issueRules,
+		location:        location,
+		defaultFilter:   defaultFilter,
+	}, nil
+}
+
+// openLedger gives time logger the ledger, commands that only read open
+// it read only
+func (a *app) openLedger(readOnly bool) error {
+	ledgerPath := viper.GetString("ledger-path")
+
+	ledger, err := interfaces.NewBoltWorkLogLedger(ledgerPath, readOnly)
+
+	if err != nil {
+		return fmt.Errorf("Cannot open ledger %s: %s", ledgerPath, err)
+	}
+
+	a.ledger = ledger
+	a.timeLogger.WorkLogLedger = ledger
+
+	return nil
+}
+
+func (a *app) Close() error {
+	if a.ledger == nil {
+		return nil
+	}
+
+	return a.ledger.Close()
+}
+
+// timeEntryFilter reads default filter, due dates are days
+// and both of them are included
+func timeEntryFilter(location *time.Location) (domain.TimeEntryFilter, error) {
+	filter := domain.TimeEntryFilter{
+		Lists:         viper.GetStringSlice("trello-filter-lists"),
+		IncludeClosed: viper.GetBool("trello-filter-include-closed"),
+		Labels:        viper.GetStringSlice("trello-filter-labels"),
+		Members:       viper.GetStringSlice("trello-filter-members"),
+	}
+
+	var err error
+
+	if dueAfter := viper.GetString("trello-filter-due-after"); dueAfter != "" {
+		if filter.DueAfter, err = time.ParseInLocation("2006-01-02", dueAfter, location); err != nil {
+			return filter, err
+		}
+	}
+
+	if dueBefore := viper.GetString("trello-filter-due-before"); dueBefore != "" {
+		if filter.DueBefore, err = time.ParseInLocation("2006-01-02", dueBefore, location); err != nil {
+			return filter, err
+		}
+
+		filter.DueBefore = filter.DueBefore.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+// togglProjects reads map of issue project short names to toggl project ids
+func togglProjects() (map[string]int, error) {
+	projects := map[string]int{}
+
+	for shortName, projectId := range viper.GetStringMapString("toggl-projects") {
+		id, err := strconv.Atoi(projectId)
+
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %s", shortName, err)
+		}
+
+		projects[domain.ProjectKey(shortName)] = id
+	}
+
+	return projects, nil
+}
+
+// durationRounding reads rounding policies, nil when durations are
+// logged as they are
+func durationRounding() (*usecases.DurationRounding, error) {
+	config := interfaces.RoundingConfig{
+		Mode:      viper.GetString("rounding-mode"),
+		Increment: viper.GetInt("rounding-increment"),
+		Minimum:   viper.GetInt("rounding-minimum"),
+		DailyCap:  viper.GetInt("rounding-daily-cap"),
+	}
+
+	if err := viper.UnmarshalKey("rounding-projects", &config.Projects); err != nil {
+		return nil, err
+	}
+
+	return interfaces.NewDurationRounding(config)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/vizualni/meyougotrack/interfaces"
+)
+
+// requiredConfig must be set for anything to work
+var requiredConfig = []string{
+	"trello-api-key",
+	"trello-api-token",
+	"trello-board-id",
+	"youtrack-api-key",
+	"youtrack-base-url",
+}
+
+// runCli runs command given by args and returns exit code
+func runCli(args []string) int {
+	if len(args) >= 2 && args[0] == "config" && args[1] == "check" {
+		return checkConfig()
+	}
+
+	if err := readConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return interfaces.ExitFailure
+	}
+
+	app, err := newApp()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return interfaces.ExitFailure
+	}
+
+	if err := app.openLedger(readOnlyCommand(args)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return interfaces.ExitFailure
+	}
+
+	defer app.Close()
+
+	cli := interfaces.NewCli(app.timeLogger, app.location, app.defaultFilter, os.Stdin, os.Stdout, os.Stderr)
+
+	if len(args) >= 2 && args[0] == "rules" && args[1] == "explain" {
+		return cli.ExplainIssueRules(app.issueRules, args[2:])
+	}
+
+	return cli.Run(args)
+}
+
+// readOnlyCommand tells if command never writes to the ledger, e.g. list
+// or sync --dry-run
+func readOnlyCommand(args []string) bool {
+	switch args[0] {
+	case "list", "report", "rules":
+		return true
+	case "sync":
+		for _, arg := range args[1:] {
+			if arg == "--dry-run" || arg == "-dry-run" || arg == "--dry-run=true" || arg == "-dry-run=true" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkConfig reads config and builds everything from it without
+// talking to any of the services or opening the ledger
+func checkConfig() int {
+	if err := readConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return interfaces.ExitFailure
+	}
+
+	fmt.Printf("config: %s\n", viper.ConfigFileUsed())
+
+	code := interfaces.ExitOk
+
+	for _, key := range requiredConfig {
+		if viper.GetString(key) == "" {
+			fmt.Fprintf(os.Stderr, "%s is not set\n", key)
+			code = interfaces.ExitFailure
+		}
+	}
+
+	app, err := newApp()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return interfaces.ExitFailure
+	}
+
+	for _, source := range app.timeLogger.TimeSources {
+		fmt.Printf("time source: %s\n", source.Name())
+	}
+
+	for _, destination := range app.timeLogger.Destinations {
+		required := "optional"
+
+		if destination.Required {
+			required = "required"
+		}
+
+		fmt.Printf("destination: %s (%s)\n", destination.Sink.Name(), required)
+	}
+
+	if code == interfaces.ExitOk {
+		fmt.Println("config ok")
+	}
+
+	return code
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/spf13/viper"
 
@@ -12,7 +13,6 @@ import (
 
 	"github.com/vizualni/meyougotrack/interfaces"
 	_ "github.com/vizualni/meyougotrack/statik"
-	"github.com/vizualni/meyougotrack/usecases"
 )
 
 func init() {
@@ -20,44 +20,48 @@ func init() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 
-	err := viper.ReadInConfig()
+	viper.SetDefault("ledger-path", "ledger.db")
+	viper.SetDefault("timezone", "Local")
+	viper.SetDefault("trello-workers", 8)
+	viper.SetDefault("youtrack-cache-ttl", "5m")
+	viper.SetDefault("youtrack-workers", 4)
+	viper.SetDefault("issue-bare-keys", true)
+	viper.SetDefault("issue-projects-from-youtrack", true)
+	viper.SetDefault("git-session-timeout", "2h")
+	viper.SetDefault("git-first-commit", "30m")
+	viper.SetDefault("pull-requests-session-timeout", "1h")
+	viper.SetDefault("pull-requests-first-event", "15m")
+	viper.SetDefault("heartbeats-idle-gap", "15m")
+	viper.SetDefault("rounding-mode", "none")
+	viper.SetDefault("rounding-increment", 15)
+}
 
-	if err != nil {
-		panic(fmt.Errorf("Cannot read config file: %s", err))
+// main serves the web on :8787 when run without arguments,
+// otherwise runs the cli command, e.g. sync --yes
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCli(os.Args[1:]))
 	}
 
-}
+	if err := readConfig(); err != nil {
+		panic(err)
+	}
 
-func main() {
+	app, err := newApp()
 
-	trelloApikey := viper.GetString("trello-api-key")
-	trelloApiToken := viper.GetString("trello-api-token")
-	trelloBoardId := viper.GetString("trello-board-id")
-	trelloDoingListName := viper.GetString("trello-doing-list-name")
-
-	youtrackApiKey := viper.GetString("youtrack-api-key")
-	youtrackBaseUrl := viper.GetString("youtrack-base-url")
-
-	fmt.Println(youtrackBaseUrl)
-	trelloClient := interfaces.NewTrelloAdlioClient(
-		trelloApikey,
-		trelloApiToken,
-		trelloBoardId,
-		trelloDoingListName,
-	)
-
-	youtrackClient := interfaces.NewYouTrackClient(
-		youtrackBaseUrl,
-		youtrackApiKey,
-	)
-
-	timeLoggerInteractor := &usecases.TimeLoggerInteractor{
-		YouTrackRepository: youtrackClient,
-		TrelloRepository:   trelloClient,
-		IssueIdExtractor:   interfaces.SimpleRegexIssueIdExtractor{},
+	if err != nil {
+		panic(err)
+	}
+
+	if err := app.openLedger(false); err != nil {
+		panic(err)
 	}
 
-	web := interfaces.NewWeb(timeLoggerInteractor)
+	defer app.Close()
+
+	fmt.Println(viper.GetString("youtrack-base-url"))
+
+	web := interfaces.NewWeb(app.timeLogger, app.location)
 
 	//statikFS, err := fs.New()
 	statikFS := http.Dir("./static")
@@ -71,10 +75,13 @@ func main() {
 	mux.Handle("/static/", http.FileServer(statikFS))
 
 	mux.HandleFunc("/", serveIndex(statikFS))
-	mux.HandleFunc("/get-time", web.GetLoggableItems(trelloBoardId, trelloDoingListName))
+	mux.HandleFunc("/get-time", web.GetLoggableItems(app.defaultFilter))
 	mux.HandleFunc("/save-time", web.Save())
+	mux.HandleFunc("/worktypes", web.WorkTypes())
+	mux.HandleFunc("/debug/cache", web.CacheStats(app.issueRepository))
+	mux.HandleFunc("/explain-issue-rules", web.ExplainIssueRules(app.issueRules))
 
-	http.ListenAndServe(":8787", mux)
+	http.ListenAndServe(":8787", interfaces.RecoverPanics(mux))
 }
 
 func serveIndex(system http.FileSystem) http.HandlerFunc {
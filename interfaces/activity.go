@@ -0,0 +1,49 @@
+package interfaces
+
+import (
+	"strings"
+	"time"
+
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// underscores would otherwise be taken as part of the key, e.g. feature_mat-12
+var branchSeparators = strings.NewReplacer("/", " ", "_", " ")
+
+// extractIssueId returns issue id extractor finds in the first of the
+// texts having one, empty when none does
+func extractIssueId(extractor usecases.IssueIdExtractor, texts ...string) string {
+	for _, text := range texts {
+		if issueId, err := extractor.Extract(text); err == nil {
+			return issueId
+		}
+	}
+
+	return ""
+}
+
+// extractBranchIssueId finds issue id in branch names like feature/mat-12-login,
+// keys are upper cased since branch names are usually lower case
+func extractBranchIssueId(extractor usecases.IssueIdExtractor, branch string) string {
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+	branch = strings.TrimPrefix(branch, "refs/remotes/")
+
+	return extractIssueId(extractor, strings.ToUpper(branchSeparators.Replace(branch)))
+}
+
+// sessionDurations estimates time spent before each of sorted activity
+// timestamps. Gap to previous activity is counted if it is within session
+// timeout, otherwise the activity starts a new session and counts as first.
+func sessionDurations(times []time.Time, sessionTimeout time.Duration, first time.Duration) []time.Duration {
+	durations := make([]time.Duration, len(times))
+
+	for index := range times {
+		durations[index] = first
+
+		if index > 0 && times[index].Sub(times[index-1]) <= sessionTimeout {
+			durations[index] = times[index].Sub(times[index-1])
+		}
+	}
+
+	return durations
+}
@@ -0,0 +1,474 @@
+package interfaces
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// exit codes of cli commands, failure includes partial failures so that
+// cron notices them
+const (
+	ExitOk      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+const defaultWorkType = "Work"
+
+// Cli runs the same time logger as the web, but from command line.
+type Cli struct {
+	timeLogger    usecases.TimeLogger
+	location      *time.Location
+	defaultFilter domain.TimeEntryFilter
+	in            io.Reader
+	out           io.Writer
+	errOut        io.Writer
+}
+
+// Run runs command given by args, e.g. sync --from 2018-01-01 --yes,
+// and returns exit code.
+func (cli *Cli) Run(args []string) int {
+	if len(args) == 0 {
+		cli.usage()
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "list":
+		return cli.List(args[1:])
+	case "sync":
+		return cli.Sync(args[1:])
+	case "report":
+		return cli.Report(args[1:])
+	default:
+		fmt.Fprintf(cli.errOut, "unknown command %q\n", args[0])
+		cli.usage()
+		return ExitUsage
+	}
+}
+
+func (cli *Cli) usage() {
+	fmt.Fprintln(cli.errOut, `usage:
+  list   [--from DAY] [--to DAY]                         print loggable items
+  sync   [--from DAY] [--to DAY] [--dry-run] [--yes]     save items linked to issues
+  report [--from DAY] [--to DAY]                         print time per day and issue
+  rules explain --title TITLE [--source S] [--label L] [--list L]
+                                                         print which issue rule links the entry
+  config check                                           validate config without talking to services
+days are YYYY-MM-DD and both are included, they default to today`)
+}
+
+// List prints loggable items as a table
+func (cli *Cli) List(args []string) int {
+	flags, from, to := cli.flagSet("list")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	items, code := cli.loggableItems(*from, *to)
+
+	if code != ExitOk {
+		return code
+	}
+
+	table := tabwriter.NewWriter(cli.out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "DATE\tSOURCE\tISSUE\tDURATION\tSYNCED\tTITLE")
+
+	for _, item := range items {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.Entry.Date.Format(queryDateFormat),
+			item.Entry.Source,
+			itemIssueId(item),
+			itemDuration(item),
+			syncedDuration(item),
+			item.Entry.Title,
+		)
+	}
+
+	table.Flush()
+
+	return ExitOk
+}
+
+// Sync saves items linked to an issue, after asking unless --yes is given.
+// Items without issue can't be saved and are only listed as skipped.
+func (cli *Cli) Sync(args []string) int {
+	flags, from, to := cli.flagSet("sync")
+	dryRun := flags.Bool("dry-run", false, "validate logs and print requests without sending them")
+	yes := flags.Bool("yes", false, "save without asking")
+	workType := flags.String("worktype", defaultWorkType, "work type of items which have none")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	items, code := cli.loggableItems(*from, *to)
+
+	if code != ExitOk {
+		return code
+	}
+
+	var logs []usecases.SaveTimeLog
+
+	table := tabwriter.NewWriter(cli.out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "DATE\tISSUE\tDURATION\tWORKTYPE\tTITLE")
+
+	for _, item := range items {
+		if item.Issue == nil || item.Entry.Duration <= 0 {
+			continue
+		}
+
+		log := saveTimeLogOf(item, *workType)
+		logs = append(logs, log)
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+			log.Date.Format(queryDateFormat),
+			log.IssueId,
+			formatMinutes(log.Duration),
+			log.WorkType,
+			log.Title,
+		)
+	}
+
+	table.Flush()
+
+	if skipped := len(items) - len(logs); skipped > 0 {
+		fmt.Fprintf(cli.out, "%d items without issue or duration skipped\n", skipped)
+	}
+
+	if len(logs) == 0 {
+		fmt.Fprintln(cli.out, "nothing to sync")
+		return ExitOk
+	}
+
+	if *dryRun {
+		results, err := cli.timeLogger.PreviewWorklogs(logs)
+
+		cli.printResults(results)
+		cli.printRequests(results)
+
+		if err != nil {
+			cli.printError(err)
+			return ExitFailure
+		}
+
+		fmt.Fprintf(cli.out, "dry run, %d logs not saved\n", len(logs))
+		return ExitOk
+	}
+
+	if !*yes {
+		confirmed, err := cli.confirm(fmt.Sprintf("save %d logs?", len(logs)))
+
+		if err != nil {
+			fmt.Fprintln(cli.errOut, "cannot read answer, use --yes to sync without asking")
+			return ExitUsage
+		}
+
+		if !confirmed {
+			fmt.Fprintln(cli.out, "nothing saved")
+			return ExitOk
+		}
+	}
+
+	results, err := cli.timeLogger.SaveWorklogs(logs)
+
+	cli.printResults(results)
+
+	if err != nil {
+		cli.printError(err)
+		return ExitFailure
+	}
+
+	return ExitOk
+}
+
+// Report prints time per day and issue together with how much of it was synced
+func (cli *Cli) Report(args []string) int {
+	flags, from, to := cli.flagSet("report")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	items, code := cli.loggableItems(*from, *to)
+
+	if code != ExitOk {
+		return code
+	}
+
+	type reportRow struct {
+		day     string
+		issueId string
+		tracked int
+		synced  int
+	}
+
+	var rows []*reportRow
+	rowIndex := map[string]*reportRow{}
+	var tracked, synced int
+
+	for _, item := range items {
+		day := item.Entry.Date.Format(queryDateFormat)
+		issueId := itemIssueId(item)
+		key := day + "|" + issueId
+
+		row, ok := rowIndex[key]
+
+		if !ok {
+			row = &reportRow{day: day, issueId: issueId}
+			rowIndex[key] = row
+			rows = append(rows, row)
+		}
+
+		row.tracked += int(item.Entry.Duration)
+		tracked += int(item.Entry.Duration)
+
+		if item.Ledger != nil {
+			row.synced += item.Ledger.Duration
+			synced += item.Ledger.Duration
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].day != rows[j].day {
+			return rows[i].day < rows[j].day
+		}
+
+		return rows[i].issueId < rows[j].issueId
+	})
+
+	table := tabwriter.NewWriter(cli.out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "DATE\tISSUE\tTRACKED\tSYNCED")
+
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", row.day, row.issueId, formatMinutes(row.tracked), formatMinutes(row.synced))
+	}
+
+	fmt.Fprintf(table, "TOTAL\t\t%s\t%s\n", formatMinutes(tracked), formatMinutes(synced))
+
+	table.Flush()
+
+	return ExitOk
+}
+
+// ExplainIssueRules prints how each rule deals with the entry given by args
+// and the mapping of the first one that matched, exits with failure when
+// none of them did
+func (cli *Cli) ExplainIssueRules(rules *IssueRules, args []string) int {
+	flags := flag.NewFlagSet("rules explain", flag.ContinueOnError)
+	flags.SetOutput(cli.errOut)
+
+	title := flags.String("title", "", "entry title")
+	source := flags.String("source", "", "entry source, e.g. trello or ics")
+	label := flags.String("label", "", "comma separated entry labels")
+	list := flags.String("list", "", "list the entry is in")
+
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if rules == nil {
+		fmt.Fprintln(cli.errOut, "issue-rules-file is not set")
+		return ExitFailure
+	}
+
+	entry := domain.TimeEntry{
+		Title:  *title,
+		Source: *source,
+		List:   *list,
+		Date:   time.Now().In(cli.location),
+	}
+
+	entry.Labels = splitQueryList(*label)
+
+	mapping, explanations := rules.Explain(entry)
+
+	for _, explanation := range explanations {
+		if explanation.Matched {
+			fmt.Fprintf(cli.out, "%s: matched\n", explanation.Rule)
+		} else {
+			fmt.Fprintf(cli.out, "%s: no match on %s\n", explanation.Rule, strings.Join(explanation.Mismatches, ", "))
+		}
+	}
+
+	if mapping == nil {
+		fmt.Fprintln(cli.out, "no rule matched")
+		return ExitFailure
+	}
+
+	fmt.Fprintf(cli.out, "issue: %s (%s)\n", mapping.IssueId, mapping.Rule)
+
+	if mapping.WorkType != "" {
+		fmt.Fprintf(cli.out, "worktype: %s\n", mapping.WorkType)
+	}
+
+	if mapping.Description != "" {
+		fmt.Fprintf(cli.out, "description: %s\n", mapping.Description)
+	}
+
+	return ExitOk
+}
+
+func (cli *Cli) flagSet(name string) (*flag.FlagSet, *string, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(cli.errOut)
+
+	from := flags.String("from", "", "first day, YYYY-MM-DD, defaults to today")
+	to := flags.String("to", "", "last day, YYYY-MM-DD, defaults to today")
+
+	return flags, from, to
+}
+
+func (cli *Cli) loggableItems(from string, to string) ([]usecases.LoggableItem, int) {
+	window, err := daysWindow(from, to, cli.location)
+
+	if err != nil {
+		cli.printError(err)
+		return nil, ExitUsage
+	}
+
+	items, err := cli.timeLogger.GetLoggableItems(cli.defaultFilter, window)
+
+	if err != nil {
+		cli.printError(err)
+		return nil, ExitFailure
+	}
+
+	return items, ExitOk
+}
+
+func (cli *Cli) printResults(results []usecases.SaveResult) {
+	table := tabwriter.NewWriter(cli.out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "ISSUE\tSTATUS\tDESTINATIONS\tTITLE\tREASON")
+
+	for _, result := range results {
+		var destinations []string
+
+		for _, destination := range result.Destinations {
+			destinations = append(destinations, fmt.Sprintf("%s:%s", destination.Destination, destination.Status))
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+			result.IssueId,
+			result.Status,
+			strings.Join(destinations, ","),
+			result.Title,
+			result.Reason,
+		)
+	}
+
+	table.Flush()
+}
+
+// printRequests prints requests dry run would send, one after another
+func (cli *Cli) printRequests(results []usecases.SaveResult) {
+	for _, result := range results {
+		if result.Request == nil {
+			continue
+		}
+
+		fmt.Fprintf(cli.out, "\n%s %s\n%s\n", result.Request.Method, result.Request.Url, result.Request.Body)
+	}
+}
+
+func (cli *Cli) printError(err error) {
+	fmt.Fprintf(cli.errOut, "error (%s): %s\n", usecases.KindOf(err), err)
+}
+
+// confirm asks yes or no question, anything but y or yes means no
+func (cli *Cli) confirm(question string) (bool, error) {
+	fmt.Fprintf(cli.out, "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(cli.in).ReadString('\n')
+
+	if err != nil && answer == "" {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes", nil
+}
+
+// saveTimeLogOf is the log web would send for an unchanged item
+func saveTimeLogOf(item usecases.LoggableItem, workType string) usecases.SaveTimeLog {
+	if item.Entry.WorkType != "" {
+		workType = item.Entry.WorkType
+	}
+
+	return usecases.SaveTimeLog{
+		EntryId:     item.Entry.Id,
+		IssueId:     item.Issue.Id,
+		Title:       item.Entry.Title,
+		Duration:    int(item.Entry.Duration),
+		Date:        item.Entry.Date,
+		WorkType:    workType,
+		Description: item.Entry.Description,
+	}
+}
+
+func itemIssueId(item usecases.LoggableItem) string {
+	if item.Issue != nil {
+		return item.Issue.Id
+	}
+
+	return "-"
+}
+
+// itemDuration is the duration to be logged, followed by the raw one
+// when rounding changed it
+func itemDuration(item usecases.LoggableItem) string {
+	duration := formatMinutes(int(item.Entry.Duration))
+
+	if rounding := item.Entry.Rounding; rounding != nil && rounding.Raw != item.Entry.Duration {
+		return fmt.Sprintf("%s (raw %s)", duration, formatMinutes(int(rounding.Raw)))
+	}
+
+	return duration
+}
+
+func syncedDuration(item usecases.LoggableItem) string {
+	if item.Ledger == nil {
+		return "-"
+	}
+
+	return formatMinutes(item.Ledger.Duration)
+}
+
+// formatMinutes prints minutes like 1h30m
+func formatMinutes(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+func NewCli(timeLogger usecases.TimeLogger, location *time.Location, defaultFilter domain.TimeEntryFilter, in io.Reader, out io.Writer, errOut io.Writer) *Cli {
+	return &Cli{
+		timeLogger:    timeLogger,
+		location:      location,
+		defaultFilter: defaultFilter,
+		in:            in,
+		out:           out,
+		errOut:        errOut,
+	}
+}
@@ -0,0 +1,215 @@
+package interfaces
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+func cliItems() []usecases.LoggableItem {
+	day := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	return []usecases.LoggableItem{
+		{
+			Entry: &domain.TimeEntry{Id: "card1", Source: "trello", Title: "MAT-1 login", Duration: 90, Date: day},
+			Issue: &domain.Issue{Id: "MAT-1"},
+		},
+		{
+			Entry: &domain.TimeEntry{Id: "card2", Source: "trello", Title: "no issue", Duration: 30, Date: day},
+		},
+		{
+			Entry:  &domain.TimeEntry{Id: "ics:1", Source: "ics", Title: "standup", WorkType: "Meeting", Duration: 15, Date: day},
+			Issue:  &domain.Issue{Id: "MAT-2"},
+			Ledger: &domain.WorkLogLedgerEntry{Duration: 15},
+		},
+	}
+}
+
+func newTestCli(timeLogger usecases.TimeLogger, in string) (*Cli, *bytes.Buffer, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+
+	return NewCli(timeLogger, time.UTC, domain.TimeEntryFilter{}, strings.NewReader(in), out, errOut), out, errOut
+}
+
+func TestCliListPrintsTable(t *testing.T) {
+	var requestedWindow domain.TimeWindow
+
+	cli, out, _ := newTestCli(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			requestedWindow = window
+			return cliItems(), nil
+		},
+	}, "")
+
+	if code := cli.Run([]string{"list", "--from", "2018-01-01", "--to", "2018-01-02"}); code != ExitOk {
+		t.Fatal("Unexpected exit code", code)
+	}
+
+	if requestedWindow.From != time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC) || requestedWindow.To != time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC) {
+		t.Fatal("Unexpected window", requestedWindow)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	if len(lines) != 4 {
+		t.Fatal("Expected header and three rows", out.String())
+	}
+
+	if !strings.Contains(lines[1], "MAT-1") || !strings.Contains(lines[1], "1h30m") {
+		t.Fatal("Unexpected row", lines[1])
+	}
+
+	if !strings.Contains(lines[3], "15m  ") {
+		t.Fatal("Synced duration not shown", lines[3])
+	}
+}
+
+func TestCliSyncSavesLinkedItemsWithYes(t *testing.T) {
+	var saved []usecases.SaveTimeLog
+
+	cli, out, _ := newTestCli(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			return cliItems(), nil
+		},
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			saved = logs
+			return []usecases.SaveResult{{IssueId: "MAT-1", Status: usecases.StatusSaved}, {IssueId: "MAT-2", Status: usecases.StatusAlreadySynced}}, nil
+		},
+	}, "")
+
+	if code := cli.Run([]string{"sync", "--yes"}); code != ExitOk {
+		t.Fatal("Unexpected exit code", code, out.String())
+	}
+
+	if len(saved) != 2 {
+		t.Fatal("Expected only items with issue to be saved", saved)
+	}
+
+	if saved[0].IssueId != "MAT-1" || saved[0].WorkType != defaultWorkType || saved[0].Duration != 90 {
+		t.Fatal("Unexpected log", saved[0])
+	}
+
+	if saved[1].WorkType != "Meeting" {
+		t.Fatal("Work type of entry should be kept", saved[1])
+	}
+}
+
+func TestCliSyncDryRunSavesNothing(t *testing.T) {
+	cli, out, _ := newTestCli(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			return cliItems(), nil
+		},
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			t.Fatal("Dry run should not save")
+			return nil, nil
+		},
+		previewWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			return []usecases.SaveResult{{
+				IssueId: "MAT-1",
+				Status:  usecases.StatusWouldSave,
+				Request: &usecases.WorkLogRequest{Method: "POST", Url: "https://youtrack/api/issues/MAT-1/timeTracking/workItems", Body: []byte(`{"duration":{"minutes":90}}`)},
+			}}, nil
+		},
+	}, "")
+
+	if code := cli.Run([]string{"sync", "--dry-run"}); code != ExitOk {
+		t.Fatal("Unexpected exit code", code)
+	}
+
+	if !strings.Contains(out.String(), `{"duration":{"minutes":90}}`) {
+		t.Fatal("Request body should be printed", out.String())
+	}
+}
+
+func TestCliSyncAsksForConfirmation(t *testing.T) {
+	saved := false
+
+	timeLogger := &timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			return cliItems(), nil
+		},
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			saved = true
+			return nil, nil
+		},
+	}
+
+	cli, _, _ := newTestCli(timeLogger, "n\n")
+
+	if code := cli.Run([]string{"sync"}); code != ExitOk || saved {
+		t.Fatal("Nothing should be saved when answer is no", code)
+	}
+
+	cli, _, _ = newTestCli(timeLogger, "")
+
+	if code := cli.Run([]string{"sync"}); code != ExitUsage || saved {
+		t.Fatal("Nothing should be saved without answer", code)
+	}
+
+	cli, _, _ = newTestCli(timeLogger, "y\n")
+
+	if code := cli.Run([]string{"sync"}); code != ExitOk || !saved {
+		t.Fatal("Logs should be saved when answer is yes", code)
+	}
+}
+
+func TestCliSyncExitsWithFailureOnPartialFailure(t *testing.T) {
+	cli, out, errOut := newTestCli(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			return cliItems(), nil
+		},
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			results := []usecases.SaveResult{
+				{IssueId: "MAT-1", Status: usecases.StatusSaved},
+				{IssueId: "MAT-2", Status: usecases.StatusRejected, Reason: "youtrack rejected"},
+			}
+			return results, usecases.WorkLogsRejected{Results: results}
+		},
+	}, "")
+
+	if code := cli.Run([]string{"sync", "--yes"}); code != ExitFailure {
+		t.Fatal("Unexpected exit code", code)
+	}
+
+	if !strings.Contains(out.String(), "youtrack rejected") {
+		t.Fatal("Results should be printed", out.String())
+	}
+
+	if !strings.Contains(errOut.String(), string(usecases.ErrorPartialFailure)) {
+		t.Fatal("Error kind should be printed", errOut.String())
+	}
+}
+
+func TestCliReportSumsTimePerDayAndIssue(t *testing.T) {
+	cli, out, _ := newTestCli(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			return cliItems(), nil
+		},
+	}, "")
+
+	if code := cli.Run([]string{"report"}); code != ExitOk {
+		t.Fatal("Unexpected exit code", code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	total := strings.Fields(lines[len(lines)-1])
+
+	if len(total) != 3 || total[1] != "2h15m" || total[2] != "15m" {
+		t.Fatal("Unexpected total", lines[len(lines)-1])
+	}
+}
+
+func TestCliWithInvalidArguments(t *testing.T) {
+	cli, _, _ := newTestCli(&timeLoggerMock{}, "")
+
+	for _, args := range [][]string{nil, {"unknown"}, {"list", "--from", "yesterday"}, {"sync", "--nope"}} {
+		if code := cli.Run(args); code != ExitUsage {
+			t.Fatal("Unexpected exit code", args, code)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package interfaces
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const gitSourceName = "git"
+
+const gitLogFormat = "%H%x1f%ae%x1f%an%x1f%aI%x1f%S%x1f%s%x1f%b%x1e"
+
+// GitLogSource is a time source estimating time spent from commits in local
+// git repositories. Commits closer than session timeout belong to the same
+// session and the gap between them is counted as work, first commit of
+// a session counts as firstCommit.
+type GitLogSource struct {
+	repositories   []string
+	authors        []string // emails, everybody's commits if empty
+	sessionTimeout time.Duration
+	firstCommit    time.Duration
+	location       *time.Location
+	extractor      usecases.IssueIdExtractor
+	run            func(dir string, args ...string) ([]byte, error)
+}
+
+type gitCommit struct {
+	hash        string
+	authorEmail string
+	authorName  string
+	date        time.Time
+	branch      string
+	subject     string
+	body        string
+}
+
+func (g *GitLogSource) Name() string {
+	return gitSourceName
+}
+
+// GetTimeEntries returns one entry per author, day and issue. Commits
+// without issue id in message or branch name are grouped under no issue.
+func (g *GitLogSource) GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error) {
+	args := []string{"log", "--all", "--source", "--no-merges", "--format=" + gitLogFormat}
+
+	// commits just before the window can start a session that continues into it
+	if !window.From.IsZero() {
+		args = append(args, "--since="+window.From.Add(-g.sessionTimeout).Format(time.RFC3339))
+	}
+
+	if !window.To.IsZero() {
+		args = append(args, "--until="+window.To.Format(time.RFC3339))
+	}
+
+	seen := map[string]bool{}
+	var commits []gitCommit
+
+	for _, repository := range g.repositories {
+		output, err := g.run(repository, args...)
+
+		if err != nil {
+			return nil, usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot read git log of %s", repository)
+		}
+
+		parsed, err := parseGitLog(output)
+
+		if err != nil {
+			return nil, usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot parse git log of %s", repository)
+		}
+
+		for _, commit := range parsed {
+			// the same repository can be checked out more than once
+			if seen[commit.hash] || !g.isAuthor(commit) {
+				continue
+			}
+
+			seen[commit.hash] = true
+			commits = append(commits, commit)
+		}
+	}
+
+	return g.entries(commits, window), nil
+}
+
+func (g *GitLogSource) isAuthor(commit gitCommit) bool {
+	if len(g.authors) == 0 {
+		return true
+	}
+
+	for _, author := range g.authors {
+		if strings.EqualFold(author, commit.authorEmail) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entries estimates durations per author session and sums them by day and issue
+func (g *GitLogSource) entries(commits []gitCommit, window domain.TimeWindow) []domain.TimeEntry {
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].date.Before(commits[j].date)
+	})
+
+	commitsByAuthor := map[string][]gitCommit{}
+	var authors []string
+
+	for _, commit := range commits {
+		author := strings.ToLower(commit.authorEmail)
+
+		if _, ok := commitsByAuthor[author]; !ok {
+			authors = append(authors, author)
+		}
+
+		commitsByAuthor[author] = append(commitsByAuthor[author], commit)
+	}
+
+	var entries []domain.TimeEntry
+	var durations []time.Duration
+	entryIndex := map[string]int{}
+
+	for _, author := range authors {
+		authorCommits := commitsByAuthor[author]
+		times := make([]time.Time, len(authorCommits))
+
+		for index, commit := range authorCommits {
+			times[index] = commit.date
+		}
+
+		for index, duration := range sessionDurations(times, g.sessionTimeout, g.firstCommit) {
+			commit := authorCommits[index]
+
+			if !window.From.IsZero() && commit.date.Before(window.From) {
+				continue
+			}
+
+			if !window.To.IsZero() && !commit.date.Before(window.To) {
+				continue
+			}
+
+			issueId := g.issueIdOf(commit)
+			day := domain.StartOfDay(commit.date, g.location)
+			key := fmt.Sprintf("%s|%s|%s", author, day.Format(domain.LedgerDateFormat), issueId)
+
+			entry, ok := entryIndex[key]
+
+			if !ok {
+				entry = len(entries)
+				entryIndex[key] = entry
+				entries = append(entries, domain.TimeEntry{
+					Id:      fmt.Sprintf("%s:%s:%s", gitSourceName, author, issueId),
+					Source:  gitSourceName,
+					IssueId: issueId,
+					Title:   strings.TrimSpace(issueId + " " + commit.subject),
+					Date:    day,
+					Start:   commit.date.Add(-duration),
+					Members: []string{commit.authorEmail},
+				})
+				durations = append(durations, 0)
+			}
+
+			durations[entry] += duration
+
+			entries[entry].Duration = int64(durations[entry].Minutes())
+			entries[entry].ExternalId = commit.hash
+			entries[entry].End = commit.date
+
+			if !strings.Contains(entries[entry].Description+"\n", commit.subject+"\n") {
+				entries[entry].Description = strings.TrimSpace(entries[entry].Description + "\n" + commit.subject)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+
+	return entries
+}
+
+// issueIdOf prefers issue mentioned in commit message over the one in branch name
+func (g *GitLogSource) issueIdOf(commit gitCommit) string {
+	if issueId := extractIssueId(g.extractor, commit.subject, commit.body); issueId != "" {
+		return issueId
+	}
+
+	return extractBranchIssueId(g.extractor, commit.branch)
+}
+
+// parseGitLog reads output of git log in gitLogFormat
+func parseGitLog(output []byte) ([]gitCommit, error) {
+	var commits []gitCommit
+
+	for _, record := range bytes.Split(output, []byte{0x1e}) {
+		record = bytes.TrimSpace(record)
+
+		if len(record) == 0 {
+			continue
+		}
+
+		fields := strings.Split(string(record), "\x1f")
+
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("unexpected git log record %q", record)
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[3])
+
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, gitCommit{
+			hash:        fields[0],
+			authorEmail: fields[1],
+			authorName:  fields[2],
+			date:        date,
+			branch:      fields[4],
+			subject:     fields[5],
+			body:        strings.TrimSpace(fields[6]),
+		})
+	}
+
+	return commits, nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	command := exec.Command("git", append([]string{"-C", dir}, args...)...)
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+
+	output, err := command.Output()
+
+	if err != nil && stderr.Len() > 0 {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return output, err
+}
+
+func NewGitLogSource(repositories []string, authors []string, sessionTimeout time.Duration, firstCommit time.Duration, location *time.Location, extractor usecases.IssueIdExtractor) *GitLogSource {
+	return &GitLogSource{
+		repositories:   repositories,
+		authors:        authors,
+		sessionTimeout: sessionTimeout,
+		firstCommit:    firstCommit,
+		location:       location,
+		extractor:      extractor,
+		run:            runGit,
+	}
+}
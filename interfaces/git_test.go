@@ -0,0 +1,202 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+func gitLogRecord(hash, email string, date time.Time, branch, subject, body string) string {
+	return strings.Join([]string{hash, email, "Name", date.Format(time.RFC3339), branch, subject, body}, "\x1f") + "\x1e\n"
+}
+
+func newTestGitLogSource(logs map[string]string) *GitLogSource {
+	source := NewGitLogSource(nil, nil, time.Hour, 30*time.Minute, time.UTC, NewIssueKeyExtractor(true, []string{"MAT"}, nil, time.Minute))
+
+	for repository := range logs {
+		source.repositories = append(source.repositories, repository)
+	}
+
+	source.run = func(dir string, args ...string) ([]byte, error) {
+		log, ok := logs[dir]
+
+		if !ok {
+			return nil, errors.New("not a git repository")
+		}
+
+		return []byte(log), nil
+	}
+
+	return source
+}
+
+func TestGitEstimatesDurationsFromCommitGaps(t *testing.T) {
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	source := newTestGitLogSource(map[string]string{
+		"repo": gitLogRecord("c1", "ana@example.com", day.Add(9*time.Hour), "refs/heads/master", "MAT-1 start", "") +
+			gitLogRecord("c2", "ana@example.com", day.Add(9*time.Hour+40*time.Minute), "refs/heads/master", "MAT-1 continue", "") +
+			// more than session timeout later, counts as first commit again
+			gitLogRecord("c3", "ana@example.com", day.Add(14*time.Hour), "refs/heads/master", "MAT-1 after lunch", "") +
+			gitLogRecord("c4", "ana@example.com", day.Add(14*time.Hour+10*time.Minute), "refs/heads/master", "fix typo", "Refs MAT-2"),
+	})
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(day, day, time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatal("Expected entry per issue", entries)
+	}
+
+	if entries[0].IssueId != "MAT-1" || entries[0].Duration != 30+40+30 {
+		t.Fatal("Wrong estimate for MAT-1", entries[0])
+	}
+
+	if entries[0].Description != "MAT-1 start\nMAT-1 continue\nMAT-1 after lunch" {
+		t.Fatal("Expected subjects of commits as description", entries[0].Description)
+	}
+
+	if entries[1].IssueId != "MAT-2" || entries[1].Duration != 10 {
+		t.Fatal("Issue id should be taken from commit body", entries[1])
+	}
+
+	if entries[0].Id != "git:ana@example.com:MAT-1" || entries[0].Source != "git" || entries[0].ExternalId != "c3" {
+		t.Fatal("Unexpected entry identity", entries[0])
+	}
+}
+
+func TestGitGroupsByAuthorAndDay(t *testing.T) {
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	source := newTestGitLogSource(map[string]string{
+		"first": gitLogRecord("c1", "ana@example.com", day.Add(10*time.Hour), "refs/heads/feature/mat-7-login", "login form", "") +
+			gitLogRecord("c2", "ivo@example.com", day.Add(10*time.Hour+5*time.Minute), "refs/heads/feature/mat-7-login", "login api", ""),
+		"second": gitLogRecord("c3", "Ana@example.com", day.Add(34*time.Hour), "refs/heads/master", "cleanup", "") +
+			// same commit seen through another checkout
+			gitLogRecord("c1", "ana@example.com", day.Add(10*time.Hour), "refs/heads/feature/mat-7-login", "login form", ""),
+	})
+	source.authors = []string{"ana@example.com"}
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(day, day.AddDate(0, 0, 1), time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatal("Expected entry per day of selected author only", entries)
+	}
+
+	if entries[0].IssueId != "MAT-7" || entries[0].Duration != 30 || !entries[0].Date.Equal(day) {
+		t.Fatal("Issue id should be taken from branch name", entries[0])
+	}
+
+	if entries[1].IssueId != "" || entries[1].Title != "cleanup" || !entries[1].Date.Equal(day.AddDate(0, 0, 1)) {
+		t.Fatal("Commit without issue should still be listed", entries[1])
+	}
+}
+
+func TestGitSessionStartedBeforeWindow(t *testing.T) {
+	day := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	source := newTestGitLogSource(map[string]string{
+		"repo": gitLogRecord("c1", "ana@example.com", day.Add(-20*time.Minute), "", "MAT-1 late", "") +
+			gitLogRecord("c2", "ana@example.com", day.Add(15*time.Minute), "", "MAT-1 very late", ""),
+	})
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(day, day, time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 1 || entries[0].Duration != 35 {
+		t.Fatal("Expected gap to commit before the window to be counted", entries)
+	}
+}
+
+func TestGitUnreadableRepository(t *testing.T) {
+	source := newTestGitLogSource(map[string]string{})
+	source.repositories = []string{"missing"}
+
+	_, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if usecases.KindOf(err) != usecases.ErrorUpstreamUnavailable {
+		t.Fatal("Expected upstream unavailable error", err)
+	}
+}
+
+func TestGitReadsRealRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "meyougotrack-git")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	git := func(date time.Time, args ...string) {
+		command := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		command.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Ana", "GIT_AUTHOR_EMAIL=ana@example.com",
+			"GIT_COMMITTER_NAME=Ana", "GIT_COMMITTER_EMAIL=ana@example.com",
+			"GIT_AUTHOR_DATE="+date.Format(time.RFC3339), "GIT_COMMITTER_DATE="+date.Format(time.RFC3339),
+		)
+
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatal(err, string(output))
+		}
+	}
+
+	git(day, "init", "-q")
+	git(day, "checkout", "-q", "-b", "feature/MAT-3-import")
+	git(day.Add(9*time.Hour), "commit", "-q", "--allow-empty", "-m", "start import")
+	git(day.Add(9*time.Hour+20*time.Minute), "commit", "-q", "--allow-empty", "-m", "finish import", "-m", "multi\nline body")
+
+	source := NewGitLogSource([]string{dir}, nil, time.Hour, 15*time.Minute, time.UTC, NewIssueKeyExtractor(true, nil, nil, time.Minute))
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(day, day, time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 1 || entries[0].IssueId != "MAT-3" || entries[0].Duration != 35 {
+		t.Fatal(fmt.Sprintf("Unexpected entries %+v", entries))
+	}
+}
+
+func TestGitIgnoresKeysOfUnknownProjects(t *testing.T) {
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	source := newTestGitLogSource(map[string]string{
+		"repo": gitLogRecord("c1", "ana@example.com", day.Add(9*time.Hour), "refs/heads/feature_utf-8", "handle UTF-8 and SHA-256", "see ISO-8601"),
+	})
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(day, day, time.UTC))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].IssueId != "" {
+		t.Fatal("Expected commit without issue", entries)
+	}
+}
@@ -0,0 +1,195 @@
+package interfaces
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const heartbeatSourceName = "heartbeat"
+
+// HeartbeatSource is a time source reading editor heartbeats exported as
+// json lines, wakatime style. Time to the next heartbeat is counted for the
+// project and branch of the heartbeat unless it is longer than idle gap.
+type HeartbeatSource struct {
+	files     []string
+	idleGap   time.Duration
+	location  *time.Location
+	extractor usecases.IssueIdExtractor
+}
+
+type heartbeat struct {
+	at      time.Time
+	project string
+	branch  string
+}
+
+type heartbeatJson struct {
+	Time    heartbeatTime `json:"time"`
+	Project string        `json:"project"`
+	Branch  string        `json:"branch"`
+}
+
+// heartbeatTime is either unix seconds with fraction or RFC3339 string
+type heartbeatTime struct {
+	time.Time
+}
+
+func (t *heartbeatTime) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		t.Time = parsed
+
+		return err
+	}
+
+	seconds, err := strconv.ParseFloat(string(data), 64)
+
+	if err != nil {
+		return fmt.Errorf("invalid heartbeat time %s", data)
+	}
+
+	whole, fraction := math.Modf(seconds)
+	t.Time = time.Unix(int64(whole), int64(fraction*float64(time.Second)))
+
+	return nil
+}
+
+func (h *HeartbeatSource) Name() string {
+	return heartbeatSourceName
+}
+
+// GetTimeEntries returns one entry per project, branch and day
+func (h *HeartbeatSource) GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error) {
+	var heartbeats []heartbeat
+
+	for _, file := range h.files {
+		fileHeartbeats, err := readHeartbeats(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		heartbeats = append(heartbeats, fileHeartbeats...)
+	}
+
+	sort.SliceStable(heartbeats, func(i, j int) bool {
+		return heartbeats[i].at.Before(heartbeats[j].at)
+	})
+
+	var entries []domain.TimeEntry
+	var durations []time.Duration
+	entryIndex := map[string]int{}
+
+	for index := 0; index+1 < len(heartbeats); index++ {
+		current, next := heartbeats[index], heartbeats[index+1]
+
+		if next.at.Sub(current.at) > h.idleGap {
+			continue
+		}
+
+		start, end, ok := window.Clip(current.at, next.at)
+
+		if !ok {
+			continue
+		}
+
+		for _, part := range domain.SplitByDay(start, end, h.location) {
+			id := fmt.Sprintf("%s:%s:%s", heartbeatSourceName, current.project, current.branch)
+			key := id + "|" + part.Day.Format(domain.LedgerDateFormat)
+
+			entry, seen := entryIndex[key]
+
+			if !seen {
+				entry = len(entries)
+				entryIndex[key] = entry
+				entries = append(entries, h.entry(id, current, part))
+				durations = append(durations, 0)
+			}
+
+			durations[entry] += part.Duration
+
+			entries[entry].Duration = int64(durations[entry].Minutes())
+			entries[entry].End = part.End
+		}
+	}
+
+	return entries, nil
+}
+
+func (h *HeartbeatSource) entry(id string, heartbeat heartbeat, day domain.DayDuration) domain.TimeEntry {
+	entry := domain.TimeEntry{
+		Id:          id,
+		Source:      heartbeatSourceName,
+		ExternalId:  heartbeat.project + "/" + heartbeat.branch,
+		Title:       heartbeat.branch,
+		Description: fmt.Sprintf("%s: %s", heartbeat.project, heartbeat.branch),
+		Date:        day.Day,
+		Start:       day.Start,
+	}
+
+	entry.IssueId = extractBranchIssueId(h.extractor, heartbeat.branch)
+
+	return entry
+}
+
+func readHeartbeats(file string) ([]heartbeat, error) {
+	data, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, usecases.NewError(usecases.ErrorNotFound, err, "cannot read heartbeats %s", file)
+	}
+
+	var heartbeats []heartbeat
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var beat heartbeatJson
+
+		if err := json.Unmarshal(scanner.Bytes(), &beat); err != nil {
+			return nil, usecases.NewError(usecases.ErrorValidation, err, "invalid heartbeat on line %d of %s", line, file)
+		}
+
+		heartbeats = append(heartbeats, heartbeat{
+			at:      beat.Time.Time,
+			project: beat.Project,
+			branch:  beat.Branch,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, usecases.NewError(usecases.ErrorValidation, err, "cannot read heartbeats %s", file)
+	}
+
+	return heartbeats, nil
+}
+
+func NewHeartbeatSource(files []string, idleGap time.Duration, location *time.Location, extractor usecases.IssueIdExtractor) *HeartbeatSource {
+	return &HeartbeatSource{
+		files:     files,
+		idleGap:   idleGap,
+		location:  location,
+		extractor: extractor,
+	}
+}
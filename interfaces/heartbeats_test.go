@@ -0,0 +1,86 @@
+package interfaces
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+func writeTestHeartbeats(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "meyougotrack-heartbeats")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	file.WriteString(content)
+
+	return file.Name()
+}
+
+func TestHeartbeatSessions(t *testing.T) {
+	path := writeTestHeartbeats(t, `{"time": 1514800800, "project": "app", "branch": "feature/mat-1-login", "file": "login.go"}
+{"time": 1514801100.5, "project": "app", "branch": "feature/mat-1-login", "entity": "login_test.go"}
+{"time": "2018-01-01T10:10:00Z", "project": "app", "branch": "master", "file": "main.go"}
+
+{"time": "2018-01-01T10:12:00Z", "project": "app", "branch": "master", "file": "main.go"}
+{"time": "2018-01-01T11:00:00Z", "project": "app", "branch": "feature/mat-1-login", "file": "login.go"}
+{"time": "2018-01-01T23:50:00Z", "project": "app", "branch": "feature/mat-1-login", "file": "login.go"}
+{"time": "2018-01-02T00:05:00Z", "project": "app", "branch": "feature/mat-1-login", "file": "login.go"}
+`)
+	defer os.Remove(path)
+
+	source := NewHeartbeatSource([]string{path}, 15*time.Minute, time.UTC, NewIssueKeyExtractor(true, []string{"MAT"}, nil, time.Minute))
+
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(day, day.AddDate(0, 0, 1), time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatal("Expected entry per branch and day", entries)
+	}
+
+	login, master, nextDay := entries[0], entries[1], entries[2]
+
+	// 10:00 - 10:10 and 23:50 - 00:00, idle time before 11:00 is not counted
+	if login.IssueId != "MAT-1" || login.Duration != 20 || login.Id != "heartbeat:app:feature/mat-1-login" {
+		t.Fatal("Unexpected login branch entry", login)
+	}
+
+	if master.IssueId != "" || master.Duration != 2 || master.Title != "master" {
+		t.Fatal("Unexpected master branch entry", master)
+	}
+
+	if !nextDay.Date.Equal(day.AddDate(0, 0, 1)) || nextDay.Duration != 5 || nextDay.Id != login.Id {
+		t.Fatal("Expected time after midnight on the next day", nextDay)
+	}
+}
+
+func TestHeartbeatErrors(t *testing.T) {
+	path := writeTestHeartbeats(t, "{\"time\": 1514800800}\nnot json\n")
+	defer os.Remove(path)
+
+	window := domain.NewDaysWindow(time.Now(), time.Now(), time.UTC)
+
+	_, err := NewHeartbeatSource([]string{path}, time.Minute, time.UTC, NewIssueKeyExtractor(true, []string{"MAT"}, nil, time.Minute)).GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if usecases.KindOf(err) != usecases.ErrorValidation {
+		t.Fatal("Expected validation error", err)
+	}
+
+	_, err = NewHeartbeatSource([]string{path + ".missing"}, time.Minute, time.UTC, NewIssueKeyExtractor(true, []string{"MAT"}, nil, time.Minute)).GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+}
@@ -0,0 +1,239 @@
+package interfaces
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const icalSourceName = "ics"
+
+const meetingWorkType = "Meeting"
+
+// ICalendarSource is a time source turning calendar events into meetings.
+// Calendar is read from a local file or over http on every request.
+type ICalendarSource struct {
+	calendar       string // path or http(s) url
+	httpClient     HttpClient
+	location       *time.Location
+	extractor      usecases.IssueIdExtractor
+	defaultIssueId string
+	// attendee whose declined events are skipped, by email
+	attendee string
+}
+
+func (c *ICalendarSource) Name() string {
+	return icalSourceName
+}
+
+// GetTimeEntries returns occurrences of events starting inside the window,
+// cancelled, declined and all day events are left out. Events which can't be
+// read are logged and left out too.
+func (c *ICalendarSource) GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error) {
+	data, err := c.read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	events, skipped, err := parseICalendar(data, c.location)
+
+	if err != nil {
+		return nil, usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot parse calendar %s", c.calendar)
+	}
+
+	for _, err := range skipped {
+		log.Printf("calendar %s: skipping %s", c.calendar, err)
+	}
+
+	// moved or changed occurrences of recurring events come as separate events
+	overridden := map[string]bool{}
+
+	for _, event := range events {
+		if !event.recurrenceId.IsZero() {
+			overridden[occurrenceKey(event.uid, event.recurrenceId)] = true
+		}
+	}
+
+	limit := window.To
+
+	if limit.IsZero() {
+		limit = time.Now()
+	}
+
+	var entries []domain.TimeEntry
+
+	for _, event := range events {
+		if event.allDay || event.status == "CANCELLED" || c.declined(event) {
+			continue
+		}
+
+		starts := []time.Time{event.start}
+
+		if event.rule != nil && event.recurrenceId.IsZero() {
+			starts = event.rule.occurrences(event.start, limit)
+		}
+
+		for _, start := range starts {
+			if isExcluded(event, start) || (event.rule != nil && overridden[occurrenceKey(event.uid, start)]) {
+				continue
+			}
+
+			if (!window.From.IsZero() && start.Before(window.From)) || !start.Before(limit) {
+				continue
+			}
+
+			entries = append(entries, c.entry(event, start))
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+
+	return entries, nil
+}
+
+func (c *ICalendarSource) entry(event icalEvent, start time.Time) domain.TimeEntry {
+	end := start.Add(event.end.Sub(event.start))
+
+	entry := domain.TimeEntry{
+		Id:          fmt.Sprintf("%s:%s@%s", icalSourceName, event.uid, start.UTC().Format("20060102T150405Z")),
+		Source:      icalSourceName,
+		ExternalId:  event.uid,
+		IssueId:     c.issueId(event),
+		Title:       event.summary,
+		Description: event.description,
+		WorkType:    meetingWorkType,
+		Duration:    int64(end.Sub(start).Minutes()),
+		Date:        domain.StartOfDay(start, c.location),
+		Start:       start,
+		End:         end,
+	}
+
+	if event.url != "" {
+		entry.Links = []string{event.url}
+	}
+
+	for _, attendee := range event.attendees {
+		if attendee.name != "" {
+			entry.Members = append(entry.Members, attendee.name)
+		} else {
+			entry.Members = append(entry.Members, attendee.email)
+		}
+	}
+
+	return entry
+}
+
+// issueId looks for issue in description, location and summary, in that
+// order. Default issue is left to time logger, issue rules come first.
+func (c *ICalendarSource) issueId(event icalEvent) string {
+	if c.extractor != nil {
+		for _, text := range []string{event.description, event.location, event.summary} {
+			if issueId, err := c.extractor.Extract(text); err == nil {
+				return issueId
+			}
+		}
+	}
+
+	return ""
+}
+
+// DefaultIssueId is where meetings not linked to any issue are logged
+func (c *ICalendarSource) DefaultIssueId() string {
+	return c.defaultIssueId
+}
+
+func (c *ICalendarSource) declined(event icalEvent) bool {
+	if c.attendee == "" {
+		return false
+	}
+
+	for _, attendee := range event.attendees {
+		if strings.EqualFold(attendee.email, c.attendee) {
+			return attendee.partstat == "DECLINED"
+		}
+	}
+
+	return false
+}
+
+func (c *ICalendarSource) read() ([]byte, error) {
+	if !strings.HasPrefix(c.calendar, "http://") && !strings.HasPrefix(c.calendar, "https://") {
+		data, err := ioutil.ReadFile(c.calendar)
+
+		if err != nil {
+			return nil, usecases.NewError(usecases.ErrorNotFound, err, "cannot read calendar %s", c.calendar)
+		}
+
+		return data, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, c.calendar, nil)
+
+	if err != nil {
+		return nil, usecases.NewError(usecases.ErrorValidation, err, "invalid calendar url %s", c.calendar)
+	}
+
+	response, err := c.httpClient.Do(request)
+
+	if err != nil {
+		return nil, usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot fetch calendar %s", c.calendar)
+	}
+
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return nil, usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot fetch calendar %s", c.calendar)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		kind := usecases.ErrorUpstreamUnavailable
+
+		switch response.StatusCode {
+		case http.StatusNotFound:
+			kind = usecases.ErrorNotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			kind = usecases.ErrorAuthFailed
+		}
+
+		return nil, usecases.NewError(kind, nil, "cannot fetch calendar %s: %s", c.calendar, response.Status)
+	}
+
+	return data, nil
+}
+
+func isExcluded(event icalEvent, start time.Time) bool {
+	for _, exdate := range event.exdates {
+		if exdate.Equal(start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func occurrenceKey(uid string, start time.Time) string {
+	return fmt.Sprintf("%s|%d", uid, start.Unix())
+}
+
+func NewICalendarSource(calendar string, location *time.Location, extractor usecases.IssueIdExtractor, defaultIssueId string, attendee string) *ICalendarSource {
+	return &ICalendarSource{
+		calendar:       calendar,
+		httpClient:     &OfficialHttpClientAdapter{&http.Client{Timeout: 30 * time.Second}},
+		location:       location,
+		extractor:      extractor,
+		defaultIssueId: defaultIssueId,
+		attendee:       attendee,
+	}
+}
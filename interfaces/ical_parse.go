@@ -0,0 +1,298 @@
+package interfaces
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalProperty is a single content line, e.g. DTSTART;TZID=Europe/Zagreb:20180101T090000
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icalEvent is a VEVENT with properties we care about already decoded
+type icalEvent struct {
+	uid          string
+	summary      string
+	description  string
+	location     string
+	url          string
+	status       string
+	start        time.Time
+	end          time.Time
+	duration     *time.Duration // end is start plus duration, known once event ends
+	allDay       bool
+	rule         *icalRule
+	exdates      []time.Time
+	recurrenceId time.Time
+	attendees    []icalAttendee
+}
+
+type icalAttendee struct {
+	email    string
+	name     string
+	partstat string
+}
+
+// parseICalendar returns all events of the calendar, floating times are
+// taken in given location. Events which can't be read, e.g. because of an
+// unsupported rule, are skipped and returned with the reason instead of
+// failing the whole calendar.
+func parseICalendar(data []byte, location *time.Location) ([]icalEvent, []error, error) {
+	var events []icalEvent
+	var skipped []error
+	var event *icalEvent
+	var eventErr error
+	depth := 0
+
+	for _, line := range unfoldICalLines(data) {
+		property, err := parseICalProperty(line)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case property.name == "BEGIN" && property.value == "VEVENT" && depth == 0:
+			event = &icalEvent{}
+			eventErr = nil
+			continue
+		case property.name == "END" && property.value == "VEVENT" && depth == 0:
+			if event == nil {
+				return nil, nil, fmt.Errorf("unexpected END:VEVENT")
+			}
+			if eventErr == nil && event.start.IsZero() {
+				eventErr = fmt.Errorf("no DTSTART")
+			}
+			if eventErr != nil {
+				skipped = append(skipped, fmt.Errorf("event %s: %s", event.uid, eventErr))
+				event = nil
+				continue
+			}
+			// DURATION may come before DTSTART
+			if event.duration != nil {
+				event.end = event.start.Add(*event.duration)
+			}
+			if event.end.IsZero() {
+				event.end = event.start
+			}
+			events = append(events, *event)
+			event = nil
+			continue
+		}
+
+		if event == nil {
+			continue
+		}
+
+		// skip alarms and other components nested in the event
+		if property.name == "BEGIN" {
+			depth++
+			continue
+		}
+
+		if property.name == "END" {
+			depth--
+			continue
+		}
+
+		if depth > 0 {
+			continue
+		}
+
+		if err := event.set(property, location); err != nil && eventErr == nil {
+			eventErr = err
+		}
+	}
+
+	return events, skipped, nil
+}
+
+func (e *icalEvent) set(property icalProperty, location *time.Location) error {
+	var err error
+
+	switch property.name {
+	case "UID":
+		e.uid = property.value
+	case "SUMMARY":
+		e.summary = unescapeICalText(property.value)
+	case "DESCRIPTION":
+		e.description = unescapeICalText(property.value)
+	case "LOCATION":
+		e.location = unescapeICalText(property.value)
+	case "URL":
+		e.url = property.value
+	case "STATUS":
+		e.status = strings.ToUpper(property.value)
+	case "DTSTART":
+		e.start, e.allDay, err = parseICalTime(property, location)
+	case "DTEND":
+		e.end, _, err = parseICalTime(property, location)
+	case "DURATION":
+		var duration time.Duration
+		if duration, err = parseICalDuration(property.value); err == nil {
+			e.duration = &duration
+		}
+	case "RRULE":
+		e.rule, err = parseICalRule(property.value, location)
+	case "EXDATE":
+		for _, value := range strings.Split(property.value, ",") {
+			var exdate time.Time
+			exdate, _, err = parseICalTime(icalProperty{params: property.params, value: value}, location)
+			if err != nil {
+				break
+			}
+			e.exdates = append(e.exdates, exdate)
+		}
+	case "RECURRENCE-ID":
+		e.recurrenceId, _, err = parseICalTime(property, location)
+	case "ATTENDEE":
+		e.attendees = append(e.attendees, icalAttendee{
+			email:    strings.TrimPrefix(strings.ToLower(property.value), "mailto:"),
+			name:     property.params["CN"],
+			partstat: strings.ToUpper(property.params["PARTSTAT"]),
+		})
+	}
+
+	return err
+}
+
+// unfoldICalLines joins lines continued with leading whitespace
+func unfoldICalLines(data []byte) []string {
+	var lines []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// parseICalProperty splits content line on name, params and value,
+// params can be quoted and contain ; and :
+func parseICalProperty(line string) (icalProperty, error) {
+	property := icalProperty{params: map[string]string{}}
+
+	var parts []string
+	quoted := false
+	start := 0
+	valueStart := -1
+
+	for i, char := range line {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == ';' && !quoted:
+			parts = append(parts, line[start:i])
+			start = i + 1
+		case char == ':' && !quoted:
+			parts = append(parts, line[start:i])
+			valueStart = i + 1
+		}
+
+		if valueStart >= 0 {
+			break
+		}
+	}
+
+	if valueStart < 0 {
+		return property, fmt.Errorf("invalid content line %q", line)
+	}
+
+	property.name = strings.ToUpper(parts[0])
+	property.value = line[valueStart:]
+
+	for _, param := range parts[1:] {
+		nameValue := strings.SplitN(param, "=", 2)
+
+		if len(nameValue) == 2 {
+			property.params[strings.ToUpper(nameValue[0])] = strings.Trim(nameValue[1], `"`)
+		}
+	}
+
+	return property, nil
+}
+
+// parseICalTime reads DATE or DATE-TIME value, the bool tells if it was a date
+func parseICalTime(property icalProperty, location *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(property.value)
+
+	if property.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		date, err := time.ParseInLocation("20060102", value, location)
+		return date, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		date, err := time.Parse("20060102T150405Z", value)
+		return date, false, err
+	}
+
+	if tzid, ok := property.params["TZID"]; ok {
+		// calendars exported from outlook use windows zone names go doesn't know
+		if tzLocation, err := time.LoadLocation(tzid); err == nil {
+			location = tzLocation
+		}
+	}
+
+	date, err := time.ParseInLocation("20060102T150405", value, location)
+
+	return date, false, err
+}
+
+var icalDurationRegex = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseICalDuration reads durations like PT1H30M or P1D
+func parseICalDuration(value string) (time.Duration, error) {
+	match := icalDurationRegex.FindStringSubmatch(value)
+
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var duration time.Duration
+
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+
+		amount, err := strconv.Atoi(match[i+2])
+
+		if err != nil {
+			return 0, err
+		}
+
+		duration += time.Duration(amount) * unit
+	}
+
+	if match[1] == "-" {
+		duration = -duration
+	}
+
+	return duration, nil
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,291 @@
+package interfaces
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalMaxOccurrences stops expanding rules that never end
+const icalMaxOccurrences = 10000
+
+// icalRule is a subset of RRULE: FREQ, INTERVAL, COUNT, UNTIL, BYDAY and
+// BYMONTH for yearly rules. Rules without BYDAY repeat on the day of DTSTART.
+type icalRule struct {
+	frequency string
+	interval  int
+	count     int
+	until     time.Time
+	days      []icalRuleDay
+	months    []time.Month
+}
+
+// icalRuleDay is a BYDAY value, e.g. 2TU is the second tuesday and -1FR
+// the last friday of the month, or of the year in yearly rules. Ordinal is
+// zero for every such day.
+type icalRuleDay struct {
+	ordinal int
+	weekday time.Weekday
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+func parseICalRule(value string, location *time.Location) (*icalRule, error) {
+	rule := &icalRule{interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		nameValue := strings.SplitN(part, "=", 2)
+
+		if len(nameValue) != 2 {
+			continue
+		}
+
+		var err error
+
+		switch strings.ToUpper(nameValue[0]) {
+		case "FREQ":
+			rule.frequency = strings.ToUpper(nameValue[1])
+		case "INTERVAL":
+			rule.interval, err = strconv.Atoi(nameValue[1])
+		case "COUNT":
+			rule.count, err = strconv.Atoi(nameValue[1])
+		case "UNTIL":
+			rule.until, _, err = parseICalTime(icalProperty{value: nameValue[1]}, location)
+		case "BYDAY":
+			for _, value := range strings.Split(nameValue[1], ",") {
+				day, ok := parseICalRuleDay(value)
+
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", value)
+				}
+
+				rule.days = append(rule.days, day)
+			}
+		case "BYMONTH":
+			for _, value := range strings.Split(nameValue[1], ",") {
+				var month int
+
+				if month, err = strconv.Atoi(value); err == nil && (month < 1 || month > 12) {
+					err = fmt.Errorf("month %d is out of range", month)
+				}
+
+				if err != nil {
+					break
+				}
+
+				rule.months = append(rule.months, time.Month(month))
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE %q: %s", value, err)
+		}
+	}
+
+	switch rule.frequency {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported RRULE frequency %q", rule.frequency)
+	}
+
+	if rule.interval < 1 {
+		rule.interval = 1
+	}
+
+	for _, day := range rule.days {
+		if day.ordinal != 0 && rule.frequency != "MONTHLY" && rule.frequency != "YEARLY" {
+			return nil, fmt.Errorf("BYDAY with ordinal needs monthly or yearly RRULE, got %q", value)
+		}
+	}
+
+	return rule, nil
+}
+
+func parseICalRuleDay(value string) (icalRuleDay, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	if len(value) < 2 {
+		return icalRuleDay{}, false
+	}
+
+	weekday, ok := icalWeekdays[value[len(value)-2:]]
+
+	if !ok {
+		return icalRuleDay{}, false
+	}
+
+	day := icalRuleDay{weekday: weekday}
+
+	if ordinal := value[:len(value)-2]; ordinal != "" {
+		var err error
+
+		if day.ordinal, err = strconv.Atoi(ordinal); err != nil || day.ordinal == 0 || day.ordinal > 53 || day.ordinal < -53 {
+			return icalRuleDay{}, false
+		}
+	}
+
+	return day, true
+}
+
+// occurrences returns starts of all occurrences beginning before limit,
+// dtstart included. Wall clock time of dtstart is kept across DST changes.
+func (r *icalRule) occurrences(dtstart time.Time, limit time.Time) []time.Time {
+	var starts []time.Time
+
+	add := func(start time.Time) bool {
+		if start.Before(dtstart) {
+			return true
+		}
+
+		if !start.Before(limit) || (!r.until.IsZero() && start.After(r.until)) {
+			return false
+		}
+
+		if r.count > 0 && len(starts) >= r.count {
+			return false
+		}
+
+		starts = append(starts, start)
+
+		return len(starts) < icalMaxOccurrences
+	}
+
+	for period := 0; period <= icalMaxOccurrences; period++ {
+		var candidates []time.Time
+
+		switch r.frequency {
+		case "DAILY":
+			candidates = []time.Time{dtstart.AddDate(0, 0, period*r.interval)}
+		case "WEEKLY":
+			candidates = r.weekCandidates(dtstart, period)
+		case "MONTHLY":
+			if len(r.days) > 0 {
+				first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(period*r.interval), 1, 0, 0, 0, 0, dtstart.Location())
+				candidates = r.dayCandidates(dtstart, first, first.AddDate(0, 1, 0))
+				break
+			}
+			candidate := dtstart.AddDate(0, period*r.interval, 0)
+			// 31st doesn't happen in shorter months
+			if candidate.Day() != dtstart.Day() {
+				continue
+			}
+			candidates = []time.Time{candidate}
+		case "YEARLY":
+			candidates = r.yearCandidates(dtstart, dtstart.Year()+period*r.interval)
+		}
+
+		for _, candidate := range candidates {
+			if !add(candidate) {
+				return starts
+			}
+		}
+	}
+
+	return starts
+}
+
+// weekCandidates lists BYDAY days of the week period weeks after dtstart,
+// weeks start on monday
+func (r *icalRule) weekCandidates(dtstart time.Time, period int) []time.Time {
+	if len(r.days) == 0 {
+		return []time.Time{dtstart.AddDate(0, 0, 7*period*r.interval)}
+	}
+
+	monday := dtstart.AddDate(0, 0, -((int(dtstart.Weekday())+6)%7)+7*period*r.interval)
+
+	var candidates []time.Time
+
+	for offset := 0; offset < 7; offset++ {
+		day := monday.AddDate(0, 0, offset)
+
+		for _, ruleDay := range r.days {
+			if day.Weekday() == ruleDay.weekday {
+				candidates = append(candidates, day)
+			}
+		}
+	}
+
+	return candidates
+}
+
+// yearCandidates lists occurrences in the year, by BYMONTH and BYDAY when
+// they are given and on the day of dtstart otherwise
+func (r *icalRule) yearCandidates(dtstart time.Time, year int) []time.Time {
+	if len(r.days) == 0 {
+		months := r.months
+
+		if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+
+		var candidates []time.Time
+
+		for _, month := range months {
+			candidate := time.Date(year, month, dtstart.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+			// 29th of february doesn't happen in every year
+			if candidate.Day() == dtstart.Day() {
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+		return candidates
+	}
+
+	if len(r.months) == 0 {
+		first := time.Date(year, time.January, 1, 0, 0, 0, 0, dtstart.Location())
+		return r.dayCandidates(dtstart, first, first.AddDate(1, 0, 0))
+	}
+
+	var candidates []time.Time
+
+	for _, month := range r.months {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, dtstart.Location())
+		candidates = append(candidates, r.dayCandidates(dtstart, first, first.AddDate(0, 1, 0))...)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	return candidates
+}
+
+// dayCandidates lists BYDAY days between from and to, at the wall clock
+// time of dtstart. Ordinals count from the start, or from the end when
+// negative.
+func (r *icalRule) dayCandidates(dtstart time.Time, from time.Time, to time.Time) []time.Time {
+	var candidates []time.Time
+
+	for _, ruleDay := range r.days {
+		var days []time.Time
+
+		for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == ruleDay.weekday {
+				days = append(days, time.Date(day.Year(), day.Month(), day.Day(), dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location()))
+			}
+		}
+
+		switch {
+		case ruleDay.ordinal == 0:
+			candidates = append(candidates, days...)
+		case ruleDay.ordinal > 0 && ruleDay.ordinal <= len(days):
+			candidates = append(candidates, days[ruleDay.ordinal-1])
+		case ruleDay.ordinal < 0 && -ruleDay.ordinal <= len(days):
+			candidates = append(candidates, days[len(days)+ruleDay.ordinal])
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	return candidates
+}
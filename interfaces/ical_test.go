@@ -0,0 +1,310 @@
+package interfaces
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Daily standup
+DTSTART;TZID=Europe/Zagreb:20180326T093000
+DURATION:PT15M
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=6
+EXDATE;TZID=Europe/Zagreb:20180328T093000
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:ignored
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID;TZID=Europe/Zagreb:20180330T093000
+SUMMARY:Daily standup (moved)
+DTSTART;TZID=Europe/Zagreb:20180330T110000
+DTEND;TZID=Europe/Zagreb:20180330T113000
+END:VEVENT
+BEGIN:VEVENT
+UID:planning
+SUMMARY:Sprint planning
+DESCRIPTION:Agenda\, see https://youtrack.example.com/issue/MAT-12
+  and more
+DTSTART:20180327T120000Z
+DTEND:20180327T133000Z
+URL:https://meet.example.com/planning
+ATTENDEE;CN="Doe, John";PARTSTAT=ACCEPTED:mailto:john@example.com
+ATTENDEE;PARTSTAT=ACCEPTED:mailto:ana@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:declined
+SUMMARY:Boring one
+DTSTART:20180327T140000Z
+DTEND:20180327T150000Z
+ATTENDEE;PARTSTAT=DECLINED:mailto:Ana@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+SUMMARY:Cancelled one
+STATUS:CANCELLED
+DTSTART:20180327T150000Z
+DTEND:20180327T160000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20180329
+DTEND;VALUE=DATE:20180330
+END:VEVENT
+END:VCALENDAR
+`
+
+func newTestICalendarSource(calendar string) *ICalendarSource {
+	location, _ := time.LoadLocation("Europe/Zagreb")
+
+	return NewICalendarSource(calendar, location, SimpleRegexIssueIdExtractor{}, "MAT-1", "ana@example.com")
+}
+
+func writeTestCalendar(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "meyougotrack-*.ics")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	file.WriteString(strings.Replace(content, "\n", "\r\n", -1))
+
+	return file.Name()
+}
+
+func TestICalendarEvents(t *testing.T) {
+	path := writeTestCalendar(t, testCalendar)
+	defer os.Remove(path)
+
+	source := newTestICalendarSource(path)
+	window := domain.NewDaysWindow(
+		time.Date(2018, 3, 26, 0, 0, 0, 0, source.location),
+		time.Date(2018, 4, 1, 0, 0, 0, 0, source.location),
+		source.location,
+	)
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	var titles []string
+	for _, entry := range entries {
+		titles = append(titles, entry.Title)
+	}
+
+	expected := "Daily standup,Sprint planning,Daily standup (moved)"
+
+	if strings.Join(titles, ",") != expected {
+		t.Fatal("Expected", expected, "got", titles)
+	}
+
+	standup, planning, moved := entries[0], entries[1], entries[2]
+
+	if standup.Duration != 15 || standup.Start.UTC().Hour() != 7 || standup.WorkType != "Meeting" || standup.IssueId != "" {
+		t.Fatal("Unexpected standup", standup)
+	}
+
+	if planning.Duration != 90 || planning.IssueId != "MAT-12" || planning.Description != "Agenda, see https://youtrack.example.com/issue/MAT-12 and more" {
+		t.Fatal("Unexpected planning", planning)
+	}
+
+	if len(planning.Members) != 2 || planning.Members[0] != "Doe, John" || planning.Links[0] != "https://meet.example.com/planning" {
+		t.Fatal("Unexpected planning attendees or links", planning)
+	}
+
+	if moved.Duration != 30 || moved.Id == standup.Id || moved.ExternalId != "standup" {
+		t.Fatal("Unexpected moved standup", moved)
+	}
+}
+
+func TestICalendarRecurrenceCount(t *testing.T) {
+	path := writeTestCalendar(t, testCalendar)
+	defer os.Remove(path)
+
+	source := newTestICalendarSource(path)
+
+	// count of 6 covers two weeks, one of them excluded and one moved
+	window := domain.NewDaysWindow(
+		time.Date(2018, 4, 2, 0, 0, 0, 0, source.location),
+		time.Date(2018, 4, 30, 0, 0, 0, 0, source.location),
+		source.location,
+	)
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatal("Expected three occurrences in second week", entries)
+	}
+
+	for _, entry := range entries {
+		if hour := entry.Start.In(source.location).Hour(); hour != 9 {
+			t.Fatal("Wall clock time should be kept", entry.Start)
+		}
+	}
+}
+
+func TestICalendarOverHttp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/calendar.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testCalendar))
+	}))
+	defer server.Close()
+
+	window := domain.NewDaysWindow(time.Date(2018, 3, 27, 0, 0, 0, 0, time.UTC), time.Date(2018, 3, 27, 0, 0, 0, 0, time.UTC), time.UTC)
+
+	entries, err := newTestICalendarSource(server.URL+"/calendar.ics").GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil || len(entries) != 1 || entries[0].Title != "Sprint planning" {
+		t.Fatal("Expected planning only", entries, err)
+	}
+
+	_, err = newTestICalendarSource(server.URL+"/missing.ics").GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+}
+
+func TestICalendarSkipsEventsItCannotRead(t *testing.T) {
+	path := writeTestCalendar(t, `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:review
+SUMMARY:Monthly review
+DTSTART:20180313T100000Z
+DURATION:PT1H
+RRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=3
+END:VEVENT
+BEGIN:VEVENT
+UID:retro
+SUMMARY:Retro
+DTSTART:20180330T140000Z
+DURATION:PT30M
+RRULE:FREQ=MONTHLY;BYDAY=-1FR;COUNT=2
+END:VEVENT
+BEGIN:VEVENT
+UID:broken
+SUMMARY:Every second
+DTSTART:20180301T100000Z
+RRULE:FREQ=SECONDLY
+END:VEVENT
+BEGIN:VEVENT
+UID:nostart
+SUMMARY:Whenever
+END:VEVENT
+END:VCALENDAR
+`)
+	defer os.Remove(path)
+
+	source := newTestICalendarSource(path)
+	window := domain.NewDaysWindow(
+		time.Date(2018, 3, 1, 0, 0, 0, 0, source.location),
+		time.Date(2018, 5, 31, 0, 0, 0, 0, source.location),
+		source.location,
+	)
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil {
+		t.Fatal("Unreadable events should not fail the calendar", err)
+	}
+
+	var starts []string
+
+	for _, entry := range entries {
+		starts = append(starts, entry.ExternalId+" "+entry.Start.UTC().Format("2006-01-02"))
+	}
+
+	expected := "review 2018-03-13,retro 2018-03-30,review 2018-04-10,retro 2018-04-27,review 2018-05-08"
+
+	if strings.Join(starts, ",") != expected {
+		t.Fatal("Expected", expected, "got", starts)
+	}
+
+	_, skipped, err := parseICalendar([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:broken\nDTSTART:20180301T100000Z\nRRULE:FREQ=SECONDLY\nEND:VEVENT\nEND:VCALENDAR\n"), time.UTC)
+
+	if err != nil || len(skipped) != 1 || !strings.Contains(skipped[0].Error(), "event broken") {
+		t.Fatal("Expected skipped event to be reported", skipped, err)
+	}
+}
+
+func TestICalRuleOrdinalDaysOfYear(t *testing.T) {
+	rule, err := parseICalRule("FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2", time.UTC)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dtstart := time.Date(2018, 11, 22, 12, 0, 0, 0, time.UTC)
+	starts := rule.occurrences(dtstart, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	if len(starts) != 2 || !starts[0].Equal(dtstart) || !starts[1].Equal(time.Date(2019, 11, 28, 12, 0, 0, 0, time.UTC)) {
+		t.Fatal("Unexpected occurrences", starts)
+	}
+
+	if _, err := parseICalRule("FREQ=WEEKLY;BYDAY=2TU", time.UTC); err == nil {
+		t.Fatal("Expected ordinal day to need monthly or yearly rule")
+	}
+}
+
+func TestParseICalDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"PT15M":     15 * time.Minute,
+		"PT1H30M":   90 * time.Minute,
+		"P1D":       24 * time.Hour,
+		"P1W":       7 * 24 * time.Hour,
+		"P1DT2H":    26 * time.Hour,
+		"-PT5M":     -5 * time.Minute,
+		"PT1H0M30S": time.Hour + 30*time.Second,
+	}
+
+	for value, expected := range cases {
+		duration, err := parseICalDuration(value)
+
+		if err != nil || duration != expected {
+			t.Fatal("Wrong duration for", value, duration, err)
+		}
+	}
+
+	for _, value := range []string{"P", "PT", "1H", "PT1X"} {
+		if _, err := parseICalDuration(value); err == nil {
+			t.Fatal("Expected error for", value)
+		}
+	}
+}
+
+func TestParseICalendarDurationBeforeStart(t *testing.T) {
+	events, _, err := parseICalendar([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nDURATION:PT45M\nDTSTART:20180101T100000Z\nEND:VEVENT\nEND:VCALENDAR\n"), time.UTC)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || !events[0].end.Equal(time.Date(2018, 1, 1, 10, 45, 0, 0, time.UTC)) {
+		t.Fatal("Expected end to follow start", events)
+	}
+}
@@ -0,0 +1,184 @@
+package interfaces
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+type IssueCacheStats struct {
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Ttl       string `json:"ttl"`
+}
+
+type cachedIssue struct {
+	issue     domain.Issue
+	err       error
+	expiresAt time.Time
+}
+
+type cachedWorkTypes struct {
+	workTypes []string
+	err       error
+	expiresAt time.Time
+}
+
+// CachingIssueRepository remembers found issues (and issues that do not
+// exist) for ttl so that page reloads do not hit issue repository every time.
+type CachingIssueRepository struct {
+	repository usecases.IssueRepository
+	ttl        time.Duration
+	now        func() time.Time
+
+	mutex     sync.Mutex
+	issues    map[string]cachedIssue
+	workTypes map[string]cachedWorkTypes // by project key
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+func (c *CachingIssueRepository) FindIssueByIssueId(issueId string) (domain.Issue, error) {
+	c.mutex.Lock()
+
+	cached, ok := c.issues[issueId]
+
+	if ok && c.now().Before(cached.expiresAt) {
+		c.hits++
+		c.mutex.Unlock()
+		return cached.issue, cached.err
+	}
+
+	c.misses++
+	c.mutex.Unlock()
+
+	issue, err := c.repository.FindIssueByIssueId(issueId)
+
+	if err == nil || usecases.KindOf(err) == usecases.ErrorNotFound {
+		c.mutex.Lock()
+		c.issues[issueId] = cachedIssue{
+			issue:     issue,
+			err:       err,
+			expiresAt: c.now().Add(c.ttl),
+		}
+		c.mutex.Unlock()
+	}
+
+	return issue, err
+}
+
+// SaveWorkLog changes spent time of the issue so cached one is dropped
+func (c *CachingIssueRepository) SaveWorkLog(workLog domain.IssueWorkLog) (string, error) {
+	defer c.forget(workLog.IssueId)
+
+	return c.repository.SaveWorkLog(workLog)
+}
+
+func (c *CachingIssueRepository) UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	defer c.forget(workLog.IssueId)
+
+	return c.repository.UpdateWorkLog(workItemId, workLog)
+}
+
+func (c *CachingIssueRepository) DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	defer c.forget(workLog.IssueId)
+
+	return c.repository.DeleteWorkLog(workItemId, workLog)
+}
+
+// PreviewWorkLog checks that the issue exists and that its project takes
+// work logs using what is cached, work type itself is checked by time logger
+func (c *CachingIssueRepository) PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+	if _, err := c.FindIssueByIssueId(workLog.IssueId); err != nil {
+		return usecases.WorkLogRequest{}, err
+	}
+
+	if _, err := c.WorkTypes(workLog.IssueId); err != nil {
+		return usecases.WorkLogRequest{}, err
+	}
+
+	return c.repository.PreviewWorkLog(workItemId, workLog)
+}
+
+// WorkTypes are remembered per project since all of its issues share them,
+// so is a project without time tracking or one that doesn't exist
+func (c *CachingIssueRepository) WorkTypes(issueId string) ([]string, error) {
+	lister, ok := c.repository.(usecases.WorkTypeLister)
+
+	if !ok {
+		return nil, nil
+	}
+
+	projectKey := projectKeyOf(issueId)
+
+	c.mutex.Lock()
+
+	cached, ok := c.workTypes[projectKey]
+
+	if ok && projectKey != "" && c.now().Before(cached.expiresAt) {
+		c.mutex.Unlock()
+		return cached.workTypes, cached.err
+	}
+
+	c.mutex.Unlock()
+
+	workTypes, err := lister.WorkTypes(issueId)
+
+	kind := usecases.KindOf(err)
+
+	if (err == nil || kind == usecases.ErrorValidation || kind == usecases.ErrorNotFound) && projectKey != "" {
+		c.mutex.Lock()
+		c.workTypes[projectKey] = cachedWorkTypes{
+			workTypes: workTypes,
+			err:       err,
+			expiresAt: c.now().Add(c.ttl),
+		}
+		c.mutex.Unlock()
+	}
+
+	return workTypes, err
+}
+
+func (c *CachingIssueRepository) forget(issueId string) {
+	c.mutex.Lock()
+	delete(c.issues, issueId)
+	c.mutex.Unlock()
+}
+
+// Stats drops expired issues and reports what is left
+func (c *CachingIssueRepository) Stats() IssueCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+
+	for issueId, cached := range c.issues {
+		if !now.Before(cached.expiresAt) {
+			delete(c.issues, issueId)
+			c.evictions++
+		}
+	}
+
+	return IssueCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   len(c.issues),
+		Ttl:       c.ttl.String(),
+	}
+}
+
+func NewCachingIssueRepository(repository usecases.IssueRepository, ttl time.Duration) *CachingIssueRepository {
+	return &CachingIssueRepository{
+		repository: repository,
+		ttl:        ttl,
+		now:        time.Now,
+		issues:     map[string]cachedIssue{},
+		workTypes:  map[string]cachedWorkTypes{},
+	}
+}
@@ -0,0 +1,202 @@
+package interfaces
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+type countingIssueRepository struct {
+	lookups int
+}
+
+func (r *countingIssueRepository) FindIssueByIssueId(issueId string) (domain.Issue, error) {
+	r.lookups++
+
+	if issueId == "MAT-404" {
+		return domain.Issue{}, usecases.NewError(usecases.ErrorNotFound, nil, "not found")
+	}
+
+	return domain.Issue{Id: issueId}, nil
+}
+
+func (r *countingIssueRepository) SaveWorkLog(workLog domain.IssueWorkLog) (string, error) {
+	return "1-1", nil
+}
+
+func (r *countingIssueRepository) UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return nil
+}
+
+func (r *countingIssueRepository) DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return nil
+}
+
+func (r *countingIssueRepository) PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+	return usecases.WorkLogRequest{}, nil
+}
+
+func TestCachingIssueRepositoryExpiresIssues(t *testing.T) {
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	repository := &countingIssueRepository{}
+
+	cache := NewCachingIssueRepository(repository, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.FindIssueByIssueId("MAT-1")
+	cache.FindIssueByIssueId("MAT-1")
+	cache.FindIssueByIssueId("MAT-404")
+	_, err := cache.FindIssueByIssueId("MAT-404")
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Cached missing issue should still be not found", err)
+	}
+
+	if repository.lookups != 2 {
+		t.Fatal("Expected cached issues not to be looked up again", repository.lookups)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	stats := cache.Stats()
+
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Evictions != 2 || stats.Entries != 0 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	cache.FindIssueByIssueId("MAT-1")
+
+	if repository.lookups != 3 {
+		t.Fatal("Expected expired issue to be looked up again")
+	}
+}
+
+func TestCachingIssueRepositoryForgetsIssueAfterSave(t *testing.T) {
+	repository := &countingIssueRepository{}
+	cache := NewCachingIssueRepository(repository, time.Minute)
+
+	cache.FindIssueByIssueId("MAT-1")
+	cache.SaveWorkLog(domain.IssueWorkLog{IssueId: "MAT-1"})
+	cache.FindIssueByIssueId("MAT-1")
+
+	if repository.lookups != 2 {
+		t.Fatal("Expected issue to be looked up again after saving work log")
+	}
+}
+
+func TestCachingIssueRepositoryRemembersWorkTypesPerProject(t *testing.T) {
+	settingsRequests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/issues/MAT-1", "/api/issues/MAT-2":
+			w.Write([]byte(`{"project": {"id": "0-7"}}`))
+		case "/api/admin/projects/0-7/timeTrackingSettings":
+			settingsRequests++
+			w.Write([]byte(`{"enabled": true, "workItemTypes": [{"name": "Development"}, {"name": "Meeting"}]}`))
+		default:
+			t.Error("Unexpected path", r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+	}))
+	defer server.Close()
+
+	router := NewIssueRepositoryRouter(newTestYouTrackClient(server))
+	router.Route(&countingIssueRepository{}, "JIRA")
+
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	cache := NewCachingIssueRepository(router, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	for _, issueId := range []string{"MAT-1", "MAT-2"} {
+		workTypes, err := cache.WorkTypes(issueId)
+
+		if err != nil || !reflect.DeepEqual(workTypes, []string{"Development", "Meeting"}) {
+			t.Fatal("Unexpected work types", issueId, workTypes, err)
+		}
+	}
+
+	if settingsRequests != 1 {
+		t.Fatal("Expected work types to be fetched once per project", settingsRequests)
+	}
+
+	now = now.Add(2 * time.Minute)
+	cache.WorkTypes("MAT-1")
+
+	if settingsRequests != 2 {
+		t.Fatal("Expected expired work types to be fetched again", settingsRequests)
+	}
+
+	if workTypes, err := cache.WorkTypes("JIRA-1"); err != nil || len(workTypes) != 0 {
+		t.Fatal("Expected repository without work types to accept any", workTypes, err)
+	}
+}
+
+func TestCachingIssueRepositoryPreviewsWithCachedIssueAndSettings(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch r.URL.Path {
+		case "/api/issues/MAT-1":
+			w.Write([]byte(`{"idReadable": "MAT-1", "project": {"id": "0-7"}}`))
+		case "/api/admin/projects/0-7/timeTrackingSettings":
+			w.Write([]byte(`{"enabled": false}`))
+		default:
+			t.Error("Unexpected path", r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+	}))
+	defer server.Close()
+
+	cache := NewCachingIssueRepository(newTestYouTrackClient(server), time.Minute)
+	cache.FindIssueByIssueId("MAT-1")
+
+	workLog := domain.IssueWorkLog{IssueId: "MAT-1", Duration: 30}
+
+	if _, err := cache.PreviewWorkLog("", workLog); usecases.KindOf(err) != usecases.ErrorValidation {
+		t.Fatal("Expected time tracking to be disabled", err)
+	}
+
+	if requests != 3 {
+		t.Fatal("Expected cached issue to be used", requests)
+	}
+
+	if _, err := cache.PreviewWorkLog("", workLog); usecases.KindOf(err) != usecases.ErrorValidation {
+		t.Fatal("Expected time tracking to still be disabled", err)
+	}
+
+	if requests != 3 {
+		t.Fatal("Expected disabled time tracking to be cached", requests)
+	}
+}
+
+func TestCachingIssueRepositoryDoesNotCacheUnavailableWorkTypes(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cache := NewCachingIssueRepository(newTestYouTrackClient(server), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.WorkTypes("MAT-1"); usecases.KindOf(err) != usecases.ErrorUpstreamUnavailable {
+			t.Fatal("Expected youtrack to be unavailable", err)
+		}
+	}
+
+	if requests != 2 {
+		t.Fatal("Expected unavailable work types to be fetched again", requests)
+	}
+}
@@ -0,0 +1,172 @@
+package interfaces
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+var (
+	// issue urls, e.g. https://host/youtrack/issue/MAT-12 or https://host/issue/MAT-12/login-form
+	issueUrlRegex = regexp.MustCompile(`https?://\S+?/issue/([A-Za-z][A-Za-z0-9_]*-\d+)\b`)
+	bareKeyRegex  = regexp.MustCompile(`\b[A-Z][A-Z0-9_]*-\d+\b`)
+)
+
+// ProjectLister knows short names of existing projects, e.g. youtrack
+type ProjectLister interface {
+	ProjectShortNames() ([]string, error)
+}
+
+// IssueKeyExtractor finds issue ids in issue urls and, when enabled, bare
+// keys like MAT-12. Matches can be restricted to known projects, given ones
+// and those fetched from project lister. Without any known project every
+// key matches.
+type IssueKeyExtractor struct {
+	bareKeys bool
+	projects map[string]bool
+	lister   ProjectLister
+	ttl      time.Duration
+	now      func() time.Time
+
+	mutex    sync.Mutex
+	listed   map[string]bool
+	listedAt time.Time
+}
+
+func (e *IssueKeyExtractor) Extract(input string) (string, error) {
+	candidates := e.ExtractAll(input)
+
+	if len(candidates) == 0 {
+		return "", errors.New("issue id not found")
+	}
+
+	return candidates[0].IssueId, nil
+}
+
+// ExtractAll returns every issue id found in input in order of appearance,
+// a key mentioned twice is returned twice
+func (e *IssueKeyExtractor) ExtractAll(input string) []usecases.IssueIdCandidate {
+	var candidates []usecases.IssueIdCandidate
+	var urls [][]int
+
+	for _, match := range issueUrlRegex.FindAllStringSubmatchIndex(input, -1) {
+		urls = append(urls, match[:2])
+		candidates = append(candidates, usecases.IssueIdCandidate{
+			IssueId: input[match[2]:match[3]],
+			Start:   match[2],
+			End:     match[3],
+		})
+	}
+
+	if e.bareKeys {
+		for _, match := range bareKeyRegex.FindAllStringIndex(input, -1) {
+			if !insideAny(match, urls) {
+				candidates = append(candidates, usecases.IssueIdCandidate{
+					IssueId: input[match[0]:match[1]],
+					Start:   match[0],
+					End:     match[1],
+				})
+			}
+		}
+	}
+
+	known := e.knownProjects()
+
+	var allowed []usecases.IssueIdCandidate
+
+	for _, candidate := range candidates {
+		project := projectKeyOf(candidate.IssueId)
+
+		if known == nil || known[project] {
+			allowed = append(allowed, candidate)
+		}
+	}
+
+	sort.SliceStable(allowed, func(i, j int) bool {
+		return allowed[i].Start < allowed[j].Start
+	})
+
+	return allowed
+}
+
+// knownProjects merges given and listed projects, nil means no restriction.
+// Listed projects are refreshed after ttl, also when listing failed.
+func (e *IssueKeyExtractor) knownProjects() map[string]bool {
+	if e.lister == nil {
+		if len(e.projects) == 0 {
+			return nil
+		}
+
+		return e.projects
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.listedAt.IsZero() || !e.now().Before(e.listedAt.Add(e.ttl)) {
+		e.listedAt = e.now()
+
+		shortNames, err := e.lister.ProjectShortNames()
+
+		if err != nil {
+			// previously listed projects are still better than nothing
+			log.Printf("cannot list projects: %s", err)
+		} else {
+			e.listed = map[string]bool{}
+
+			for _, shortName := range shortNames {
+				e.listed[domain.ProjectKey(shortName)] = true
+			}
+		}
+	}
+
+	if e.listed == nil && len(e.projects) == 0 {
+		return nil
+	}
+
+	known := map[string]bool{}
+
+	for project := range e.projects {
+		known[project] = true
+	}
+
+	for project := range e.listed {
+		known[project] = true
+	}
+
+	return known
+}
+
+func insideAny(span []int, spans [][]int) bool {
+	for _, outer := range spans {
+		if span[0] >= outer[0] && span[1] <= outer[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewIssueKeyExtractor restricts matches to projects and, when lister is
+// not nil, to projects it lists, which are cached for ttl
+func NewIssueKeyExtractor(bareKeys bool, projects []string, lister ProjectLister, ttl time.Duration) *IssueKeyExtractor {
+	known := map[string]bool{}
+
+	for _, project := range projects {
+		known[domain.ProjectKey(project)] = true
+	}
+
+	return &IssueKeyExtractor{
+		bareKeys: bareKeys,
+		projects: known,
+		lister:   lister,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
@@ -0,0 +1,134 @@
+package interfaces
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+type projectListerMock struct {
+	shortNames []string
+	err        error
+	calls      int
+}
+
+func (l *projectListerMock) ProjectShortNames() ([]string, error) {
+	l.calls++
+	return l.shortNames, l.err
+}
+
+func TestIssueKeyExtractorFindsUrlsAndBareKeys(t *testing.T) {
+	extractor := NewIssueKeyExtractor(true, nil, nil, time.Minute)
+
+	for input, expected := range map[string]string{
+		"ABC-123 fix login":                                  "ABC-123",
+		"fix login (ABC-123)":                                "ABC-123",
+		"https://example.com/youtrack/issue/MAT-7 broken":    "MAT-7",
+		"see https://example.com/issue/MAT-7/login-form ABC": "MAT-7",
+		"https://example.com/issue/mat-7":                    "mat-7",
+	} {
+		issueId, err := extractor.Extract(input)
+
+		if err != nil || issueId != expected {
+			t.Fatal("Unexpected issue id", input, issueId, err)
+		}
+	}
+
+	if _, err := extractor.Extract("fix login in abc-123"); err == nil {
+		t.Fatal("Lower case bare keys should not match")
+	}
+
+	if _, err := NewIssueKeyExtractor(false, nil, nil, time.Minute).Extract("ABC-123 fix login"); err == nil {
+		t.Fatal("Bare keys should not match when disabled")
+	}
+}
+
+func TestIssueKeyExtractorReturnsAllCandidatesWithPositions(t *testing.T) {
+	extractor := NewIssueKeyExtractor(true, nil, nil, time.Minute)
+
+	input := "ABC-1 and https://yt.example.com/issue/MAT-22/slug, then ABC-1 again"
+
+	expected := []usecases.IssueIdCandidate{
+		{IssueId: "ABC-1", Start: 0, End: 5},
+		{IssueId: "MAT-22", Start: 39, End: 45},
+		{IssueId: "ABC-1", Start: 57, End: 62},
+	}
+
+	if candidates := extractor.ExtractAll(input); !reflect.DeepEqual(candidates, expected) {
+		t.Fatalf("Unexpected candidates %+v", candidates)
+	}
+}
+
+func TestIssueKeyExtractorRestrictsToKnownProjects(t *testing.T) {
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	lister := &projectListerMock{shortNames: []string{"MAT"}}
+
+	extractor := NewIssueKeyExtractor(true, []string{"abc"}, lister, time.Minute)
+	extractor.now = func() time.Time { return now }
+
+	candidates := extractor.ExtractAll("UTF-8 ABC-1 MAT-2 ISO-8601")
+
+	if len(candidates) != 2 || candidates[0].IssueId != "ABC-1" || candidates[1].IssueId != "MAT-2" {
+		t.Fatalf("Unexpected candidates %+v", candidates)
+	}
+
+	extractor.Extract("MAT-3")
+
+	if lister.calls != 1 {
+		t.Fatal("Projects should be cached", lister.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	lister.err = errors.New("youtrack down")
+
+	if issueId, _ := extractor.Extract("MAT-3"); issueId != "MAT-3" || lister.calls != 2 {
+		t.Fatal("Previously listed projects should be used when listing fails", issueId, lister.calls)
+	}
+}
+
+func TestIssueKeyExtractorWithoutKnownProjectsMatchesEverything(t *testing.T) {
+	extractor := NewIssueKeyExtractor(true, nil, &projectListerMock{err: errors.New("youtrack down")}, time.Minute)
+
+	if issueId, err := extractor.Extract("UTF-8 encoding"); err != nil || issueId != "UTF-8" {
+		t.Fatal("Expected any key to match", issueId, err)
+	}
+}
+
+func TestYouTrackProjectShortNamesReadsAllPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/admin/projects" {
+			t.Error("Unexpected path", r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Query().Get("$skip") == "0" {
+			w.Write([]byte(`[` + repeatJson(`{"shortName": "P"}`, youtrackPageSize) + `]`))
+			return
+		}
+
+		w.Write([]byte(`[{"shortName": "MAT"}]`))
+	}))
+	defer server.Close()
+
+	shortNames, err := newTestYouTrackClient(server).ProjectShortNames()
+
+	if err != nil || len(shortNames) != youtrackPageSize+1 || shortNames[youtrackPageSize] != "MAT" {
+		t.Fatal("Unexpected projects", len(shortNames), err)
+	}
+}
+
+func repeatJson(value string, count int) string {
+	json := value
+
+	for i := 1; i < count; i++ {
+		json += "," + value
+	}
+
+	return json
+}
@@ -0,0 +1,79 @@
+package interfaces
+
+import (
+	"strings"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// IssueRepositoryRouter sends every call to repository registered for the
+// project key of the issue, e.g. ABC for ABC-12, and to fallback otherwise.
+type IssueRepositoryRouter struct {
+	fallback     usecases.IssueRepository
+	repositories map[string]usecases.IssueRepository
+}
+
+// Route sends issues of given project keys to repository
+func (r *IssueRepositoryRouter) Route(repository usecases.IssueRepository, projectKeys ...string) {
+	for _, projectKey := range projectKeys {
+		r.repositories[domain.ProjectKey(projectKey)] = repository
+	}
+}
+
+func (r *IssueRepositoryRouter) FindIssueByIssueId(issueId string) (domain.Issue, error) {
+	return r.repositoryOf(issueId).FindIssueByIssueId(issueId)
+}
+
+func (r *IssueRepositoryRouter) SaveWorkLog(workLog domain.IssueWorkLog) (string, error) {
+	return r.repositoryOf(workLog.IssueId).SaveWorkLog(workLog)
+}
+
+func (r *IssueRepositoryRouter) UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return r.repositoryOf(workLog.IssueId).UpdateWorkLog(workItemId, workLog)
+}
+
+func (r *IssueRepositoryRouter) DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return r.repositoryOf(workLog.IssueId).DeleteWorkLog(workItemId, workLog)
+}
+
+func (r *IssueRepositoryRouter) PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+	return r.repositoryOf(workLog.IssueId).PreviewWorkLog(workItemId, workLog)
+}
+
+// WorkTypes asks repository of the issue, any work type is accepted by
+// repositories which don't know work types
+func (r *IssueRepositoryRouter) WorkTypes(issueId string) ([]string, error) {
+	if lister, ok := r.repositoryOf(issueId).(usecases.WorkTypeLister); ok {
+		return lister.WorkTypes(issueId)
+	}
+
+	return nil, nil
+}
+
+func (r *IssueRepositoryRouter) repositoryOf(issueId string) usecases.IssueRepository {
+	if repository, ok := r.repositories[projectKeyOf(issueId)]; ok {
+		return repository
+	}
+
+	return r.fallback
+}
+
+// projectKeyOf is upper cased project key of the issue, e.g. ABC for abc-12,
+// empty when issue id has none
+func projectKeyOf(issueId string) string {
+	separator := strings.LastIndex(issueId, "-")
+
+	if separator < 0 {
+		return ""
+	}
+
+	return domain.ProjectKey(issueId[:separator])
+}
+
+func NewIssueRepositoryRouter(fallback usecases.IssueRepository) *IssueRepositoryRouter {
+	return &IssueRepositoryRouter{
+		fallback:     fallback,
+		repositories: map[string]usecases.IssueRepository{},
+	}
+}
@@ -0,0 +1,205 @@
+package interfaces
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// issueRuleYaml is a rule as written in rules file, e.g.
+//
+//   - name: standup
+//     title: '(?i)^(daily )?standup'
+//     source: ics
+//     issue: MAT-1
+//     worktype: Meeting
+//     description: 'Standup {{.Date.Format "02.01."}}'
+type issueRuleYaml struct {
+	Name   string `yaml:"name"`
+	Title  string `yaml:"title"` // regular expression
+	Label  string `yaml:"label"`
+	List   string `yaml:"list"`
+	Source string `yaml:"source"`
+	Issue  string `yaml:"issue"`
+	// WorkType and Description replace those of the entry when set,
+	// description is a template executed with the entry
+	WorkType    string `yaml:"worktype"`
+	Description string `yaml:"description"`
+}
+
+type issueRule struct {
+	issueRuleYaml
+	title       *regexp.Regexp
+	description *template.Template
+}
+
+// IssueRules links entries to issue of the first rule matching them,
+// a rule matches when all of its matchers do.
+type IssueRules struct {
+	rules []issueRule
+}
+
+// IssueRuleExplanation tells whether a rule matched an entry and if not,
+// which of its matchers didn't
+type IssueRuleExplanation struct {
+	Rule       string   `json:"rule"`
+	Matched    bool     `json:"matched"`
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+func (r *IssueRules) Map(entry domain.TimeEntry) (usecases.IssueMapping, bool) {
+	for _, rule := range r.rules {
+		if len(rule.mismatches(entry)) == 0 {
+			return rule.mapping(entry), true
+		}
+	}
+
+	return usecases.IssueMapping{}, false
+}
+
+// Explain checks entry against every rule, mapping is the one Map returns
+func (r *IssueRules) Explain(entry domain.TimeEntry) (*usecases.IssueMapping, []IssueRuleExplanation) {
+	var mapping *usecases.IssueMapping
+
+	explanations := make([]IssueRuleExplanation, 0, len(r.rules))
+
+	for _, rule := range r.rules {
+		mismatches := rule.mismatches(entry)
+
+		explanations = append(explanations, IssueRuleExplanation{
+			Rule:       rule.Name,
+			Matched:    len(mismatches) == 0,
+			Mismatches: mismatches,
+		})
+
+		if len(mismatches) == 0 && mapping == nil {
+			found := rule.mapping(entry)
+			mapping = &found
+		}
+	}
+
+	return mapping, explanations
+}
+
+func (rule issueRule) mismatches(entry domain.TimeEntry) []string {
+	var mismatches []string
+
+	if rule.title != nil && !rule.title.MatchString(entry.Title) {
+		mismatches = append(mismatches, fmt.Sprintf("title %s", rule.Title))
+	}
+
+	if rule.Label != "" && !containsFold(entry.Labels, rule.Label) {
+		mismatches = append(mismatches, fmt.Sprintf("label %s", rule.Label))
+	}
+
+	if rule.List != "" && !strings.EqualFold(entry.List, rule.List) {
+		mismatches = append(mismatches, fmt.Sprintf("list %s", rule.List))
+	}
+
+	if rule.Source != "" && !strings.EqualFold(entry.Source, rule.Source) {
+		mismatches = append(mismatches, fmt.Sprintf("source %s", rule.Source))
+	}
+
+	return mismatches
+}
+
+func (rule issueRule) mapping(entry domain.TimeEntry) usecases.IssueMapping {
+	mapping := usecases.IssueMapping{
+		Rule:     rule.Name,
+		IssueId:  rule.Issue,
+		WorkType: rule.WorkType,
+	}
+
+	if rule.description != nil {
+		var description bytes.Buffer
+
+		if err := rule.description.Execute(&description, entry); err != nil {
+			log.Printf("rule %s: cannot write description: %s", rule.Name, err)
+		} else {
+			mapping.Description = description.String()
+		}
+	}
+
+	return mapping
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseIssueRules reads yaml list of rules, each of them needs an issue
+// and at least one matcher
+func ParseIssueRules(data []byte) (*IssueRules, error) {
+	var rulesYaml []issueRuleYaml
+
+	if err := yaml.UnmarshalStrict(data, &rulesYaml); err != nil {
+		return nil, err
+	}
+
+	rules := &IssueRules{}
+
+	for index, ruleYaml := range rulesYaml {
+		rule := issueRule{issueRuleYaml: ruleYaml}
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", index+1)
+		}
+
+		if rule.Issue == "" {
+			return nil, fmt.Errorf("%s has no issue", rule.Name)
+		}
+
+		if rule.Title == "" && rule.Label == "" && rule.List == "" && rule.Source == "" {
+			return nil, fmt.Errorf("%s matches everything, give it title, label, list or source", rule.Name)
+		}
+
+		var err error
+
+		if rule.Title != "" {
+			if rule.title, err = regexp.Compile(rule.Title); err != nil {
+				return nil, fmt.Errorf("%s: %s", rule.Name, err)
+			}
+		}
+
+		if rule.Description != "" {
+			if rule.description, err = template.New(rule.Name).Parse(rule.Description); err != nil {
+				return nil, fmt.Errorf("%s: %s", rule.Name, err)
+			}
+		}
+
+		rules.rules = append(rules.rules, rule)
+	}
+
+	return rules, nil
+}
+
+func LoadIssueRules(path string) (*IssueRules, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := ParseIssueRules(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,145 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+const testIssueRules = `
+- name: standup
+  title: '(?i)^(daily )?standup'
+  source: ics
+  issue: MAT-1
+  worktype: Meeting
+  description: 'Standup {{.Date.Format "02.01."}}'
+- name: support
+  label: support
+  list: done
+  issue: SUP-1
+- title: 'review'
+  issue: MAT-2
+`
+
+func parseTestIssueRules(t *testing.T) *IssueRules {
+	rules, err := ParseIssueRules([]byte(testIssueRules))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rules
+}
+
+func TestIssueRulesMapByFirstMatchingRule(t *testing.T) {
+	rules := parseTestIssueRules(t)
+	day := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	mapping, ok := rules.Map(domain.TimeEntry{Title: "Daily standup", Source: "ics", Date: day})
+
+	if !ok || mapping.Rule != "standup" || mapping.IssueId != "MAT-1" || mapping.WorkType != "Meeting" || mapping.Description != "Standup 02.01." {
+		t.Fatal("Unexpected mapping", mapping, ok)
+	}
+
+	mapping, ok = rules.Map(domain.TimeEntry{Title: "customer call", Labels: []string{"Support"}, List: "Done"})
+
+	if !ok || mapping.IssueId != "SUP-1" || mapping.Description != "" {
+		t.Fatal("Unexpected mapping", mapping, ok)
+	}
+
+	mapping, ok = rules.Map(domain.TimeEntry{Title: "standup review", Source: "trello"})
+
+	if !ok || mapping.Rule != "rule 3" || mapping.IssueId != "MAT-2" {
+		t.Fatal("Rule without name should be numbered", mapping, ok)
+	}
+
+	if _, ok := rules.Map(domain.TimeEntry{Title: "customer call", Labels: []string{"support"}, List: "Doing"}); ok {
+		t.Fatal("Rule should match only when all of its matchers do")
+	}
+}
+
+func TestIssueRulesExplain(t *testing.T) {
+	rules := parseTestIssueRules(t)
+
+	mapping, explanations := rules.Explain(domain.TimeEntry{Title: "review login", Labels: []string{"support"}})
+
+	if mapping == nil || mapping.Rule != "rule 3" {
+		t.Fatal("Unexpected mapping", mapping)
+	}
+
+	expected := []IssueRuleExplanation{
+		{Rule: "standup", Mismatches: []string{"title (?i)^(daily )?standup", "source ics"}},
+		{Rule: "support", Mismatches: []string{"list done"}},
+		{Rule: "rule 3", Matched: true},
+	}
+
+	if !reflect.DeepEqual(explanations, expected) {
+		t.Fatalf("Unexpected explanations %+v", explanations)
+	}
+}
+
+func TestParseInvalidIssueRules(t *testing.T) {
+	for _, rules := range []string{
+		"- title: standup",
+		"- issue: MAT-1",
+		"- title: '('\n  issue: MAT-1",
+		"- title: standup\n  issue: MAT-1\n  description: '{{.Nope'",
+		"- title: standup\n  issue: MAT-1\n  unknown: x",
+	} {
+		if _, err := ParseIssueRules([]byte(rules)); err == nil {
+			t.Fatal("Expected rules to be rejected", rules)
+		}
+	}
+}
+
+func TestWebExplainIssueRules(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.ExplainIssueRules(parseTestIssueRules(t)).ServeHTTP(recorder, httptest.NewRequest("GET", "/explain-issue-rules?title=customer+call&labels=backend,+support&list=Done", nil))
+
+	var response issueRulesExplanationJson
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Code != http.StatusOK || response.Mapping == nil || response.Mapping.IssueId != "SUP-1" || len(response.Rules) != 3 {
+		t.Fatal("Unexpected response", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	web.ExplainIssueRules(nil).ServeHTTP(recorder, httptest.NewRequest("GET", "/explain-issue-rules?title=x", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Fatal("Unexpected status without rules", recorder.Code)
+	}
+}
+
+func TestCliExplainIssueRules(t *testing.T) {
+	cli, out, _ := newTestCli(&timeLoggerMock{}, "")
+
+	if code := cli.ExplainIssueRules(parseTestIssueRules(t), []string{"--title", "Standup", "--source", "ics"}); code != ExitOk {
+		t.Fatal("Unexpected exit code", code)
+	}
+
+	if !strings.Contains(out.String(), "standup: matched") || !strings.Contains(out.String(), "issue: MAT-1 (standup)") {
+		t.Fatal("Unexpected output", out.String())
+	}
+
+	cli, out, _ = newTestCli(&timeLoggerMock{}, "")
+
+	if code := cli.ExplainIssueRules(parseTestIssueRules(t), []string{"--title", "lunch"}); code != ExitFailure {
+		t.Fatal("Unexpected exit code", code)
+	}
+
+	if !strings.Contains(out.String(), "support: no match on label support, list done") {
+		t.Fatal("Unexpected output", out.String())
+	}
+}
@@ -0,0 +1,273 @@
+package interfaces
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const jiraIssueFields = "summary,description,project,assignee,status,timetracking"
+
+// jiraStartedFormat is what jira expects in worklog started field
+const jiraStartedFormat = "2006-01-02T15:04:05.000-0700"
+
+// JiraClient logs work to jira cloud or server through REST API v2.
+// Jira cloud wants user email and api token, server a personal access
+// token without user.
+type JiraClient struct {
+	baseUrl    string
+	user       string
+	token      string
+	httpClient HttpClient
+}
+
+type jiraIssueJson struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Project     struct {
+			Key  string `json:"key"`
+			Name string `json:"name"`
+		} `json:"project"`
+		Assignee *struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Status struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		TimeTracking struct {
+			OriginalEstimateSeconds int `json:"originalEstimateSeconds"`
+			TimeSpentSeconds        int `json:"timeSpentSeconds"`
+		} `json:"timetracking"`
+	} `json:"fields"`
+}
+
+type jiraWorklogJson struct {
+	Id               string `json:"id,omitempty"`
+	Comment          string `json:"comment"`
+	Started          string `json:"started"`
+	TimeSpentSeconds int    `json:"timeSpentSeconds"`
+}
+
+type jiraErrorJson struct {
+	ErrorMessages []string          `json:"errorMessages"`
+	Errors        map[string]string `json:"errors"`
+}
+
+func (i jiraIssueJson) toDomain() domain.Issue {
+	issue := domain.Issue{
+		Id:          i.Key,
+		Summary:     i.Fields.Summary,
+		Description: i.Fields.Description,
+		Project: domain.Project{
+			ShortName: i.Fields.Project.Key,
+			Name:      i.Fields.Project.Name,
+		},
+		State:      i.Fields.Status.Name,
+		Estimation: i.Fields.TimeTracking.OriginalEstimateSeconds / 60,
+		SpentTime:  i.Fields.TimeTracking.TimeSpentSeconds / 60,
+	}
+
+	if i.Fields.Assignee != nil {
+		issue.Assignee = i.Fields.Assignee.DisplayName
+
+		if issue.Assignee == "" {
+			issue.Assignee = i.Fields.Assignee.Name
+		}
+	}
+
+	return issue
+}
+
+func (jiraClient *JiraClient) FindIssueByIssueId(issueId string) (domain.Issue, error) {
+	var issue jiraIssueJson
+
+	err := jiraClient.do(http.MethodGet, fmt.Sprintf("/rest/api/2/issue/%s?fields=%s", issueId, jiraIssueFields), nil, &issue)
+
+	if err != nil {
+		return domain.Issue{}, err
+	}
+
+	return issue.toDomain(), nil
+}
+
+// SaveWorkLog creates worklog, jira has no work types so type is left out
+func (jiraClient *JiraClient) SaveWorkLog(workLog domain.IssueWorkLog) (string, error) {
+	var created jiraWorklogJson
+
+	err := jiraClient.do(http.MethodPost, fmt.Sprintf("/rest/api/2/issue/%s/worklog", workLog.IssueId), jiraWorklog(workLog), &created)
+
+	if err != nil {
+		return "", err
+	}
+
+	return created.Id, nil
+}
+
+func (jiraClient *JiraClient) UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	var updated jiraWorklogJson
+
+	return jiraClient.do(http.MethodPut, fmt.Sprintf("/rest/api/2/issue/%s/worklog/%s", workLog.IssueId, workItemId), jiraWorklog(workLog), &updated)
+}
+
+func (jiraClient *JiraClient) DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return jiraClient.do(http.MethodDelete, fmt.Sprintf("/rest/api/2/issue/%s/worklog/%s", workLog.IssueId, workItemId), nil, nil)
+}
+
+// PreviewWorkLog checks that issue exists and that time tracking is on,
+// jira has it turned on or off for the whole instance
+func (jiraClient *JiraClient) PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+	if _, err := jiraClient.FindIssueByIssueId(workLog.IssueId); err != nil {
+		return usecases.WorkLogRequest{}, err
+	}
+
+	var configuration struct {
+		TimeTrackingEnabled bool `json:"timeTrackingEnabled"`
+	}
+
+	if err := jiraClient.do(http.MethodGet, "/rest/api/2/configuration", nil, &configuration); err != nil {
+		return usecases.WorkLogRequest{}, err
+	}
+
+	if !configuration.TimeTrackingEnabled {
+		return usecases.WorkLogRequest{}, usecases.NewError(usecases.ErrorValidation, nil, "time tracking is disabled in jira")
+	}
+
+	body, err := json.Marshal(jiraWorklog(workLog))
+
+	if err != nil {
+		return usecases.WorkLogRequest{}, err
+	}
+
+	request := usecases.WorkLogRequest{
+		Method: http.MethodPost,
+		Url:    fmt.Sprintf("%s/rest/api/2/issue/%s/worklog", jiraClient.baseUrl, workLog.IssueId),
+		Body:   body,
+	}
+
+	if workItemId != "" {
+		request.Method = http.MethodPut
+		request.Url += "/" + workItemId
+	}
+
+	return request, nil
+}
+
+func jiraWorklog(workLog domain.IssueWorkLog) jiraWorklogJson {
+	return jiraWorklogJson{
+		Comment:          workLog.Description,
+		Started:          workLog.Date.Format(jiraStartedFormat),
+		TimeSpentSeconds: workLog.Duration * int(time.Minute/time.Second),
+	}
+}
+
+// do sends body as json and decodes successful json response into result,
+// response is ignored when result is nil
+func (jiraClient *JiraClient) do(method string, path string, body interface{}, result interface{}) error {
+	var requestBody []byte
+
+	if body != nil {
+		var err error
+
+		if requestBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest(method, jiraClient.baseUrl+path, bytes.NewReader(requestBody))
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorValidation, err, "invalid jira url %s", path)
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	if body != nil {
+		request.Header.Add("Content-Type", "application/json")
+	}
+
+	if jiraClient.user != "" {
+		request.SetBasicAuth(jiraClient.user, jiraClient.token)
+	} else {
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jiraClient.token))
+	}
+
+	response, err := jiraClient.httpClient.Do(request)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "jira unreachable")
+	}
+
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot read jira response")
+	}
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusNoContent {
+		return jiraError(request, response, responseBody)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.Unmarshal(responseBody, result); err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "unexpected jira response")
+	}
+
+	return nil
+}
+
+// jiraError turns unsuccessful response into typed error
+func jiraError(request *http.Request, response *http.Response, body []byte) error {
+	var errorBody jiraErrorJson
+
+	json.Unmarshal(body, &errorBody)
+
+	reasons := errorBody.ErrorMessages
+
+	for field, message := range errorBody.Errors {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", field, message))
+	}
+
+	reason := strings.Join(reasons, ", ")
+
+	if reason == "" {
+		reason = response.Status
+	}
+
+	cause := errors.New(reason)
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return usecases.NewError(usecases.ErrorNotFound, cause, "jira %s not found", request.URL.Path)
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return usecases.NewError(usecases.ErrorAuthFailed, cause, "jira refused credentials")
+	case response.StatusCode >= http.StatusInternalServerError:
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, cause, "jira failed")
+	default:
+		return usecases.NewError(usecases.ErrorValidation, cause, "jira rejected %s", request.URL.Path)
+	}
+}
+
+func NewJiraClient(baseUrl, user, token string) *JiraClient {
+	return &JiraClient{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		user:       user,
+		token:      token,
+		httpClient: &OfficialHttpClientAdapter{&http.Client{Timeout: 30 * time.Second}},
+	}
+}
@@ -0,0 +1,240 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// fakeJira knows a single issue ABC-1 and keeps worklogs written to it
+type fakeJira struct {
+	worklogs map[string]jiraWorklogJson
+}
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, token, ok := r.BasicAuth(); !ok || user != "ana@example.com" || token != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/ABC-1":
+		w.Write([]byte(`{
+			"key": "ABC-1",
+			"fields": {
+				"summary": "Billing export",
+				"project": {"key": "ABC", "name": "Acme Billing"},
+				"assignee": {"name": "ana", "displayName": "Ana Anic"},
+				"status": {"name": "In Progress"},
+				"timetracking": {"originalEstimateSeconds": 14400, "timeSpentSeconds": 5400}
+			}
+		}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/ABC-1/worklog":
+		var worklog jiraWorklogJson
+		json.NewDecoder(r.Body).Decode(&worklog)
+
+		if worklog.TimeSpentSeconds <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errorMessages": [], "errors": {"timeLogged": "You must indicate the time spent working."}}`))
+			return
+		}
+
+		worklog.Id = fmt.Sprintf("%d", 10000+len(f.worklogs))
+		f.worklogs[worklog.Id] = worklog
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(worklog)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/rest/api/2/issue/ABC-1/worklog/"):
+		delete(f.worklogs, strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue/ABC-1/worklog/"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/rest/api/2/issue/ABC-1/worklog/"):
+		id := strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue/ABC-1/worklog/")
+
+		if _, ok := f.worklogs[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorMessages": ["Cannot find worklog with id: ` + id + `"]}`))
+			return
+		}
+
+		var worklog jiraWorklogJson
+		json.NewDecoder(r.Body).Decode(&worklog)
+		worklog.Id = id
+		f.worklogs[id] = worklog
+
+		json.NewEncoder(w).Encode(worklog)
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/configuration":
+		w.Write([]byte(`{"timeTrackingEnabled": true}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorMessages": ["Issue does not exist or you do not have permission to see it."]}`))
+	}
+}
+
+func newTestJiraClient(server *httptest.Server) *JiraClient {
+	client := NewJiraClient(server.URL+"/", "ana@example.com", "secret")
+	client.httpClient = &OfficialHttpClientAdapter{server.Client()}
+
+	return client
+}
+
+func TestJiraFindIssue(t *testing.T) {
+	server := httptest.NewServer(&fakeJira{worklogs: map[string]jiraWorklogJson{}})
+	defer server.Close()
+
+	issue, err := newTestJiraClient(server).FindIssueByIssueId("ABC-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := domain.Issue{
+		Id:         "ABC-1",
+		Summary:    "Billing export",
+		Project:    domain.Project{ShortName: "ABC", Name: "Acme Billing"},
+		Assignee:   "Ana Anic",
+		State:      "In Progress",
+		Estimation: 240,
+		SpentTime:  90,
+	}
+
+	if issue != expected {
+		t.Fatalf("Unexpected issue %+v", issue)
+	}
+
+	_, err = newTestJiraClient(server).FindIssueByIssueId("ABC-2")
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+}
+
+func TestJiraSaveAndUpdateWorkLog(t *testing.T) {
+	jira := &fakeJira{worklogs: map[string]jiraWorklogJson{}}
+	server := httptest.NewServer(jira)
+	defer server.Close()
+
+	client := newTestJiraClient(server)
+
+	workLog := domain.IssueWorkLog{
+		IssueId:     "ABC-1",
+		Description: "export",
+		Type:        "Development",
+		Duration:    90,
+		Date:        time.Date(2018, 1, 1, 9, 30, 0, 0, time.UTC),
+	}
+
+	id, err := client.SaveWorkLog(workLog)
+
+	if err != nil || id != "10000" {
+		t.Fatal("Expected worklog to be created", id, err)
+	}
+
+	saved := jira.worklogs[id]
+
+	if saved.TimeSpentSeconds != 5400 || saved.Started != "2018-01-01T09:30:00.000+0000" || saved.Comment != "export" {
+		t.Fatalf("Unexpected worklog %+v", saved)
+	}
+
+	workLog.Duration = 30
+
+	if err = client.UpdateWorkLog(id, workLog); err != nil || jira.worklogs[id].TimeSpentSeconds != 1800 {
+		t.Fatal("Expected worklog to be updated", err)
+	}
+
+	if err = client.UpdateWorkLog("1", workLog); usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+
+	if err = client.DeleteWorkLog(id, workLog); err != nil || len(jira.worklogs) != 0 {
+		t.Fatal("Expected worklog to be deleted", err)
+	}
+
+	workLog.Duration = 0
+	_, err = client.SaveWorkLog(workLog)
+
+	if usecases.KindOf(err) != usecases.ErrorValidation || !strings.Contains(err.Error(), "You must indicate the time spent working.") {
+		t.Fatal("Expected validation error with jira reason", err)
+	}
+}
+
+func TestJiraPreviewWorkLogWritesNothing(t *testing.T) {
+	jira := &fakeJira{worklogs: map[string]jiraWorklogJson{}}
+	server := httptest.NewServer(jira)
+	defer server.Close()
+
+	client := newTestJiraClient(server)
+	workLog := domain.IssueWorkLog{IssueId: "ABC-1", Duration: 30, Date: time.Date(2018, 1, 1, 9, 0, 0, 0, time.UTC)}
+
+	request, err := client.PreviewWorkLog("10000", workLog)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.Method != http.MethodPut || request.Url != server.URL+"/rest/api/2/issue/ABC-1/worklog/10000" || !strings.Contains(string(request.Body), `"timeSpentSeconds":1800`) {
+		t.Fatalf("Unexpected request %s %s %s", request.Method, request.Url, request.Body)
+	}
+
+	if len(jira.worklogs) != 0 {
+		t.Fatal("Preview should not write worklogs", jira.worklogs)
+	}
+
+	workLog.IssueId = "ABC-2"
+
+	if _, err = client.PreviewWorkLog("", workLog); usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected missing issue", err)
+	}
+}
+
+func TestJiraWrongCredentials(t *testing.T) {
+	server := httptest.NewServer(&fakeJira{worklogs: map[string]jiraWorklogJson{}})
+	defer server.Close()
+
+	client := newTestJiraClient(server)
+	client.token = "wrong"
+
+	_, err := client.FindIssueByIssueId("ABC-1")
+
+	if usecases.KindOf(err) != usecases.ErrorAuthFailed {
+		t.Fatal("Expected auth error", err)
+	}
+}
+
+func TestIssueRepositoryRouter(t *testing.T) {
+	jira := &fakeJira{worklogs: map[string]jiraWorklogJson{}}
+	jiraServer := httptest.NewServer(jira)
+	defer jiraServer.Close()
+
+	youtrackPaths := []string{}
+	youtrackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		youtrackPaths = append(youtrackPaths, r.URL.Path)
+		w.Write([]byte(`{"idReadable": "MAT-1", "id": "1-1"}`))
+	}))
+	defer youtrackServer.Close()
+
+	router := NewIssueRepositoryRouter(newTestYouTrackClient(youtrackServer))
+	router.Route(newTestJiraClient(jiraServer), "abc")
+
+	if issue, err := router.FindIssueByIssueId("ABC-1"); err != nil || issue.Summary != "Billing export" {
+		t.Fatal("Expected ABC issues from jira", issue, err)
+	}
+
+	if issue, err := router.FindIssueByIssueId("MAT-1"); err != nil || issue.Id != "MAT-1" {
+		t.Fatal("Expected other issues from youtrack", issue, err)
+	}
+
+	if _, err := router.SaveWorkLog(domain.IssueWorkLog{IssueId: "ABC-1", Duration: 5}); err != nil || len(jira.worklogs) != 1 {
+		t.Fatal("Expected work log in jira", err)
+	}
+
+	if _, err := router.SaveWorkLog(domain.IssueWorkLog{IssueId: "MAT-1", Duration: 5}); err != nil || len(youtrackPaths) != 2 {
+		t.Fatal("Expected work log in youtrack", youtrackPaths, err)
+	}
+}
@@ -0,0 +1,180 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	bolt "go.etcd.io/bbolt"
+)
+
+var workLogsBucket = []byte("worklogs")
+
+// ledgerLockTimeout is how long to wait for another process using the ledger
+const ledgerLockTimeout = 5 * time.Second
+
+// BoltWorkLogLedger stores synced work items in a local bolt database file.
+// File is opened only for each read or write, so web and cli can both use
+// it, read only ledger takes shared lock and never writes.
+type BoltWorkLogLedger struct {
+	path     string
+	readOnly bool
+	// bolt locks the file per open, so goroutines take turns
+	mutex sync.Mutex
+}
+
+func (ledger *BoltWorkLogLedger) Find(entryId string, date time.Time, issueId string) (*domain.WorkLogLedgerEntry, error) {
+	key := domain.LedgerKey(entryId, date, issueId)
+	entries, err := ledger.FindAll([]string{key})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries[key], nil
+}
+
+// FindAll reads entries of all keys while the file is opened once
+func (ledger *BoltWorkLogLedger) FindAll(keys []string) (map[string]*domain.WorkLogLedgerEntry, error) {
+	entries := map[string]*domain.WorkLogLedgerEntry{}
+
+	err := ledger.use(true, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket(workLogsBucket)
+
+			if bucket == nil {
+				return nil
+			}
+
+			for _, key := range keys {
+				value := bucket.Get([]byte(key))
+
+				if value == nil {
+					continue
+				}
+
+				entry := &domain.WorkLogLedgerEntry{}
+
+				if err := json.Unmarshal(value, entry); err != nil {
+					return err
+				}
+
+				// entries written before entry_id was named so have it empty
+				if entry.EntryId == "" {
+					entry.EntryId = ledgerKeyEntryId(key)
+				}
+
+				entries[key] = entry
+			}
+
+			return nil
+		})
+	})
+
+	// nothing was synced yet when read only ledger has no file
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (ledger *BoltWorkLogLedger) Save(entry domain.WorkLogLedgerEntry) error {
+	if ledger.readOnly {
+		return errors.New("ledger is read only")
+	}
+
+	value, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	return ledger.use(false, func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists(workLogsBucket)
+
+			if err != nil {
+				return err
+			}
+
+			return bucket.Put([]byte(entry.Key()), value)
+		})
+	})
+}
+
+// Close is there for callers closing what they opened, file is never
+// left open between reads and writes
+func (ledger *BoltWorkLogLedger) Close() error {
+	return nil
+}
+
+// use opens the file for f, read only when reading or when the whole
+// ledger is read only
+func (ledger *BoltWorkLogLedger) use(reading bool, f func(db *bolt.DB) error) error {
+	ledger.mutex.Lock()
+	defer ledger.mutex.Unlock()
+
+	readOnly := reading || ledger.readOnly
+
+	if readOnly {
+		if _, err := os.Stat(ledger.path); err != nil {
+			return err
+		}
+	}
+
+	db, err := bolt.Open(ledger.path, 0600, &bolt.Options{Timeout: ledgerLockTimeout, ReadOnly: readOnly})
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	return f(db)
+}
+
+// NewBoltWorkLogLedger checks that ledger at path can be used, writable
+// ledger file is created if it doesn't exist
+func NewBoltWorkLogLedger(path string, readOnly bool) (*BoltWorkLogLedger, error) {
+	ledger := &BoltWorkLogLedger{
+		path:     path,
+		readOnly: readOnly,
+	}
+
+	err := ledger.use(readOnly, func(db *bolt.DB) error {
+		if readOnly {
+			return nil
+		}
+
+		return db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(workLogsBucket)
+			return err
+		})
+	})
+
+	if err != nil && !(readOnly && os.IsNotExist(err)) {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// ledgerKeyEntryId takes entry id from key made by domain.LedgerKey
+func ledgerKeyEntryId(key string) string {
+	for i := 0; i < 2; i++ {
+		if separator := strings.LastIndex(key, "|"); separator >= 0 {
+			key = key[:separator]
+		}
+	}
+
+	return key
+}
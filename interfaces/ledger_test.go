@@ -0,0 +1,129 @@
+package interfaces
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+func TestBoltLedgerFindsSavedEntry(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ledger")
+	defer os.RemoveAll(dir)
+
+	ledger, err := NewBoltWorkLogLedger(filepath.Join(dir, "ledger.db"), false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	date := time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC)
+
+	entry, err := ledger.Find("card1", date, "MAT-123")
+
+	if err != nil || entry != nil {
+		t.Fatal("Nothing should have been found", entry, err)
+	}
+
+	ledger.Save(domain.WorkLogLedgerEntry{
+		EntryId:    "card1",
+		Date:       date.Format(domain.LedgerDateFormat),
+		IssueId:    "MAT-123",
+		WorkItemId: "1-1",
+	})
+
+	entry, err = ledger.Find("card1", date, "MAT-123")
+
+	if err != nil || entry == nil {
+		t.Fatal("Entry should have been found", err)
+	}
+
+	if entry.WorkItemId != "1-1" {
+		t.Fatal("Wrong work item id", entry.WorkItemId)
+	}
+}
+
+func TestReadOnlyBoltLedgerSharesFileWithWritableOne(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ledger")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ledger.db")
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	readOnly, err := NewBoltWorkLogLedger(path, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, err := readOnly.Find("card1", date, "MAT-1"); err != nil || entry != nil {
+		t.Fatal("Missing ledger should have nothing synced", entry, err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("Read only ledger should not create the file", err)
+	}
+
+	writable, err := NewBoltWorkLogLedger(path, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer writable.Close()
+
+	if err := writable.Save(domain.WorkLogLedgerEntry{EntryId: "card1", Date: "2018-01-01", IssueId: "MAT-1", WorkItemId: "1-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := readOnly.Find("card1", date, "MAT-1")
+
+	if err != nil || entry == nil || entry.WorkItemId != "1-1" || entry.EntryId != "card1" {
+		t.Fatal("Read only ledger should see what was saved", entry, err)
+	}
+
+	if err := readOnly.Save(domain.WorkLogLedgerEntry{EntryId: "card2"}); err == nil {
+		t.Fatal("Read only ledger should not save")
+	}
+
+	if err := writable.Save(domain.WorkLogLedgerEntry{EntryId: "card3", Date: "2018-01-01", IssueId: "MAT-1"}); err != nil {
+		t.Fatal("Writable ledger should still save", err)
+	}
+}
+
+func TestBoltLedgerFindsAllEntriesAtOnce(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ledger")
+	defer os.RemoveAll(dir)
+
+	ledger, err := NewBoltWorkLogLedger(filepath.Join(dir, "ledger.db"), false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ledger.Close()
+
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ledger.Save(domain.WorkLogLedgerEntry{EntryId: "card1", Date: "2018-01-01", IssueId: "MAT-1", WorkItemId: "1-1"})
+	ledger.Save(domain.WorkLogLedgerEntry{EntryId: "card2", Date: "2018-01-01", IssueId: "MAT-2", WorkItemId: "1-2"})
+
+	first := domain.LedgerKey("card1", date, "MAT-1")
+	second := domain.LedgerKey("card2", date, "MAT-2")
+	missing := domain.LedgerKey("card3", date, "MAT-3")
+
+	entries, err := ledger.FindAll([]string{first, second, missing})
+
+	if err != nil || len(entries) != 2 {
+		t.Fatal("Expected two entries", entries, err)
+	}
+
+	if entries[first].WorkItemId != "1-1" || entries[second].EntryId != "card2" {
+		t.Fatal("Wrong entries found", entries[first], entries[second])
+	}
+}
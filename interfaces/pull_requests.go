@@ -0,0 +1,258 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const (
+	pullRequestOpened   = "opened"
+	pullRequestCommit   = "commit"
+	pullRequestReview   = "review"
+	pullRequestComment  = "comment"
+	pullRequestApproval = "approval"
+)
+
+// pullRequest is a github pull request or gitlab merge request with
+// activity of everybody on it
+type pullRequest struct {
+	repository string
+	number     int
+	title      string
+	body       string
+	branch     string
+	author     string
+	url        string
+	events     []pullRequestEvent
+}
+
+type pullRequestEvent struct {
+	actor string
+	kind  string
+	at    time.Time
+}
+
+// pullRequestApi reads pull requests updated since given time
+type pullRequestApi interface {
+	name() string
+	pullRequests(repository string, since time.Time) ([]pullRequest, error)
+}
+
+// PullRequestSource is a time source estimating time user spent authoring
+// and reviewing pull requests from timestamps of their activity on them.
+type PullRequestSource struct {
+	api            pullRequestApi
+	repositories   []string
+	user           string
+	sessionTimeout time.Duration
+	firstEvent     time.Duration
+	location       *time.Location
+	extractor      usecases.IssueIdExtractor
+}
+
+type pullRequestActivity struct {
+	pullRequest *pullRequest
+	event       pullRequestEvent
+}
+
+func (p *PullRequestSource) Name() string {
+	return p.api.name()
+}
+
+// GetTimeEntries returns one entry per pull request and day with user's activity
+func (p *PullRequestSource) GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error) {
+	since := window.From
+
+	if !since.IsZero() {
+		since = since.Add(-p.sessionTimeout)
+	}
+
+	var activities []pullRequestActivity
+
+	for _, repository := range p.repositories {
+		pullRequests, err := p.api.pullRequests(repository, since)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for index := range pullRequests {
+			for _, event := range pullRequests[index].events {
+				if strings.EqualFold(event.actor, p.user) {
+					activities = append(activities, pullRequestActivity{&pullRequests[index], event})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].event.at.Before(activities[j].event.at)
+	})
+
+	times := make([]time.Time, len(activities))
+
+	for index, activity := range activities {
+		times[index] = activity.event.at
+	}
+
+	var entries []domain.TimeEntry
+	var durations []time.Duration
+	var kinds [][]string
+	entryIndex := map[string]int{}
+
+	for index, duration := range sessionDurations(times, p.sessionTimeout, p.firstEvent) {
+		activity := activities[index]
+		at := activity.event.at
+
+		if (!window.From.IsZero() && at.Before(window.From)) || (!window.To.IsZero() && !at.Before(window.To)) {
+			continue
+		}
+
+		day := domain.StartOfDay(at, p.location)
+		id := fmt.Sprintf("%s:%s#%d", p.api.name(), activity.pullRequest.repository, activity.pullRequest.number)
+		key := id + "|" + day.Format(domain.LedgerDateFormat)
+
+		entry, ok := entryIndex[key]
+
+		if !ok {
+			entry = len(entries)
+			entryIndex[key] = entry
+			entries = append(entries, p.entry(id, activity.pullRequest, day, at.Add(-duration)))
+			durations = append(durations, 0)
+			kinds = append(kinds, nil)
+		}
+
+		durations[entry] += duration
+
+		entries[entry].Duration = int64(durations[entry].Minutes())
+		entries[entry].End = at
+
+		if !containsString(kinds[entry], activity.event.kind) {
+			kinds[entry] = append(kinds[entry], activity.event.kind)
+		}
+	}
+
+	for index := range entries {
+		entries[index].Description += fmt.Sprintf(" (%s)", strings.Join(kinds[index], ", "))
+	}
+
+	return entries, nil
+}
+
+func (p *PullRequestSource) entry(id string, pullRequest *pullRequest, day time.Time, start time.Time) domain.TimeEntry {
+	activity := "Code review"
+
+	if strings.EqualFold(pullRequest.author, p.user) {
+		activity = "Pull request"
+	}
+
+	return domain.TimeEntry{
+		Id:          id,
+		Source:      p.api.name(),
+		ExternalId:  fmt.Sprintf("%s#%d", pullRequest.repository, pullRequest.number),
+		IssueId:     p.issueId(pullRequest),
+		Title:       pullRequest.title,
+		Description: fmt.Sprintf("%s %s#%d: %s", activity, pullRequest.repository, pullRequest.number, pullRequest.title),
+		Date:        day,
+		Start:       start,
+		Links:       []string{pullRequest.url},
+		Members:     []string{pullRequest.author},
+	}
+}
+
+// issueId looks for issue in title, body and branch name, in that order
+func (p *PullRequestSource) issueId(pullRequest *pullRequest) string {
+	if issueId := extractIssueId(p.extractor, pullRequest.title, pullRequest.body); issueId != "" {
+		return issueId
+	}
+
+	return extractBranchIssueId(p.extractor, pullRequest.branch)
+}
+
+// getJson gets url into target, errors are typed by response status
+func getJson(client HttpClient, requestUrl string, headers map[string]string, target interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorValidation, err, "invalid url %s", requestUrl)
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := client.Do(request)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "GET %s failed", request.URL.Path)
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "GET %s failed", request.URL.Path)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		kind := usecases.ErrorUpstreamUnavailable
+
+		switch response.StatusCode {
+		case http.StatusNotFound:
+			kind = usecases.ErrorNotFound
+		case http.StatusUnauthorized, http.StatusForbidden:
+			kind = usecases.ErrorAuthFailed
+		}
+
+		return usecases.NewError(kind, nil, "GET %s returned %s", request.URL.Path, response.Status)
+	}
+
+	if err = json.Unmarshal(body, target); err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "GET %s returned unexpected body", request.URL.Path)
+	}
+
+	return nil
+}
+
+// NewPullRequestSource reads github pull requests or gitlab merge requests
+// of given repositories, e.g. owner/repo or group/project
+func NewPullRequestSource(provider string, baseUrl string, token string, repositories []string, user string, sessionTimeout time.Duration, firstEvent time.Duration, location *time.Location, extractor usecases.IssueIdExtractor) (*PullRequestSource, error) {
+	// without user every pull request would quietly count as someone else's
+	if user == "" {
+		return nil, fmt.Errorf("pull request user is not set")
+	}
+
+	client := &OfficialHttpClientAdapter{&http.Client{Timeout: 30 * time.Second}}
+
+	var api pullRequestApi
+
+	switch provider {
+	case githubSourceName:
+		api = newGitHubApi(baseUrl, token, client)
+	case gitlabSourceName:
+		api = newGitLabApi(baseUrl, token, client)
+	default:
+		return nil, fmt.Errorf("unknown pull request provider %q", provider)
+	}
+
+	return &PullRequestSource{
+		api:            api,
+		repositories:   repositories,
+		user:           user,
+		sessionTimeout: sessionTimeout,
+		firstEvent:     firstEvent,
+		location:       location,
+		extractor:      extractor,
+	}, nil
+}
@@ -0,0 +1,238 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const githubSourceName = "github"
+
+const apiPageSize = 100
+
+type gitHubApi struct {
+	baseUrl    string
+	token      string
+	httpClient HttpClient
+}
+
+type gitHubUserJson struct {
+	Login string `json:"login"`
+}
+
+type gitHubPullJson struct {
+	Number  int            `json:"number"`
+	Title   string         `json:"title"`
+	Body    string         `json:"body"`
+	HtmlUrl string         `json:"html_url"`
+	User    gitHubUserJson `json:"user"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type gitHubReviewJson struct {
+	User        gitHubUserJson `json:"user"`
+	State       string         `json:"state"`
+	SubmittedAt time.Time      `json:"submitted_at"`
+}
+
+type gitHubCommentJson struct {
+	User      gitHubUserJson `json:"user"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type gitHubCommitJson struct {
+	Author *gitHubUserJson `json:"author"`
+	Commit struct {
+		Author struct {
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+}
+
+func (g *gitHubApi) name() string {
+	return githubSourceName
+}
+
+// pullRequests reads pull requests sorted by last update until the first
+// one not updated since given time
+func (g *gitHubApi) pullRequests(repository string, since time.Time) ([]pullRequest, error) {
+	var pullRequests []pullRequest
+
+	pullsUrl := fmt.Sprintf("%s/repos/%s/pulls?state=all&sort=updated&direction=desc", g.baseUrl, repository)
+
+	err := getPages(g.httpClient, pullsUrl, g.headers(), func(page []json.RawMessage) (bool, error) {
+		for _, raw := range page {
+			var pull gitHubPullJson
+
+			if err := json.Unmarshal(raw, &pull); err != nil {
+				return false, err
+			}
+
+			if !since.IsZero() && pull.UpdatedAt.Before(since) {
+				return false, nil
+			}
+
+			pullRequest, err := g.pullRequest(repository, pull)
+
+			if err != nil {
+				return false, err
+			}
+
+			pullRequests = append(pullRequests, pullRequest)
+		}
+
+		return true, nil
+	})
+
+	return pullRequests, err
+}
+
+func (g *gitHubApi) pullRequest(repository string, pull gitHubPullJson) (pullRequest, error) {
+	pullRequest := pullRequest{
+		repository: repository,
+		number:     pull.Number,
+		title:      pull.Title,
+		body:       pull.Body,
+		branch:     pull.Head.Ref,
+		author:     pull.User.Login,
+		url:        pull.HtmlUrl,
+		events: []pullRequestEvent{
+			{pull.User.Login, pullRequestOpened, pull.CreatedAt},
+		},
+	}
+
+	pullUrl := fmt.Sprintf("%s/repos/%s/pulls/%d", g.baseUrl, repository, pull.Number)
+
+	err := getPages(g.httpClient, pullUrl+"/reviews", g.headers(), func(page []json.RawMessage) (bool, error) {
+		for _, raw := range page {
+			var review gitHubReviewJson
+
+			if err := json.Unmarshal(raw, &review); err != nil {
+				return false, err
+			}
+
+			// pending reviews are not submitted yet
+			if review.SubmittedAt.IsZero() {
+				continue
+			}
+
+			kind := pullRequestReview
+			if strings.EqualFold(review.State, "APPROVED") {
+				kind = pullRequestApproval
+			}
+
+			pullRequest.events = append(pullRequest.events, pullRequestEvent{review.User.Login, kind, review.SubmittedAt})
+		}
+
+		return true, nil
+	})
+
+	if err != nil {
+		return pullRequest, err
+	}
+
+	commentUrls := []string{
+		pullUrl + "/comments",
+		fmt.Sprintf("%s/repos/%s/issues/%d/comments", g.baseUrl, repository, pull.Number),
+	}
+
+	for _, commentUrl := range commentUrls {
+		err = getPages(g.httpClient, commentUrl, g.headers(), func(page []json.RawMessage) (bool, error) {
+			for _, raw := range page {
+				var comment gitHubCommentJson
+
+				if err := json.Unmarshal(raw, &comment); err != nil {
+					return false, err
+				}
+
+				pullRequest.events = append(pullRequest.events, pullRequestEvent{comment.User.Login, pullRequestComment, comment.CreatedAt})
+			}
+
+			return true, nil
+		})
+
+		if err != nil {
+			return pullRequest, err
+		}
+	}
+
+	err = getPages(g.httpClient, pullUrl+"/commits", g.headers(), func(page []json.RawMessage) (bool, error) {
+		for _, raw := range page {
+			var commit gitHubCommitJson
+
+			if err := json.Unmarshal(raw, &commit); err != nil {
+				return false, err
+			}
+
+			// commits by authors without github account have no login
+			if commit.Author == nil {
+				continue
+			}
+
+			pullRequest.events = append(pullRequest.events, pullRequestEvent{commit.Author.Login, pullRequestCommit, commit.Commit.Author.Date})
+		}
+
+		return true, nil
+	})
+
+	return pullRequest, err
+}
+
+func (g *gitHubApi) headers() map[string]string {
+	headers := map[string]string{
+		"Accept": "application/vnd.github+json",
+	}
+
+	if g.token != "" {
+		headers["Authorization"] = "token " + g.token
+	}
+
+	return headers
+}
+
+// getPages gets page after page of a list until a short page or
+// until next says to stop
+func getPages(client HttpClient, requestUrl string, headers map[string]string, next func(page []json.RawMessage) (bool, error)) error {
+	separator := "?"
+
+	if strings.Contains(requestUrl, "?") {
+		separator = "&"
+	}
+
+	for pageNumber := 1; ; pageNumber++ {
+		var page []json.RawMessage
+
+		err := getJson(client, fmt.Sprintf("%s%sper_page=%d&page=%d", requestUrl, separator, apiPageSize, pageNumber), headers, &page)
+
+		if err != nil {
+			return err
+		}
+
+		more, err := next(page)
+
+		if err != nil {
+			return err
+		}
+
+		if !more || len(page) < apiPageSize {
+			return nil
+		}
+	}
+}
+
+func newGitHubApi(baseUrl string, token string, client HttpClient) *gitHubApi {
+	if baseUrl == "" {
+		baseUrl = "https://api.github.com"
+	}
+
+	return &gitHubApi{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		token:      token,
+		httpClient: client,
+	}
+}
@@ -0,0 +1,148 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const gitlabSourceName = "gitlab"
+
+type gitLabApi struct {
+	baseUrl    string
+	token      string
+	httpClient HttpClient
+}
+
+type gitLabUserJson struct {
+	Username string `json:"username"`
+}
+
+type gitLabMergeRequestJson struct {
+	Iid          int            `json:"iid"`
+	Title        string         `json:"title"`
+	Description  string         `json:"description"`
+	SourceBranch string         `json:"source_branch"`
+	WebUrl       string         `json:"web_url"`
+	Author       gitLabUserJson `json:"author"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+type gitLabNoteJson struct {
+	Type      string         `json:"type"`
+	Body      string         `json:"body"`
+	System    bool           `json:"system"`
+	Author    gitLabUserJson `json:"author"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (g *gitLabApi) name() string {
+	return gitlabSourceName
+}
+
+func (g *gitLabApi) pullRequests(repository string, since time.Time) ([]pullRequest, error) {
+	var pullRequests []pullRequest
+
+	projectUrl := fmt.Sprintf("%s/api/v4/projects/%s", g.baseUrl, url.PathEscape(repository))
+	mergeRequestsUrl := projectUrl + "/merge_requests?state=all&order_by=updated_at"
+
+	if !since.IsZero() {
+		mergeRequestsUrl += "&updated_after=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+
+	err := getPages(g.httpClient, mergeRequestsUrl, g.headers(), func(page []json.RawMessage) (bool, error) {
+		for _, raw := range page {
+			var mergeRequest gitLabMergeRequestJson
+
+			if err := json.Unmarshal(raw, &mergeRequest); err != nil {
+				return false, err
+			}
+
+			pullRequest, err := g.pullRequest(projectUrl, repository, mergeRequest)
+
+			if err != nil {
+				return false, err
+			}
+
+			pullRequests = append(pullRequests, pullRequest)
+		}
+
+		return true, nil
+	})
+
+	return pullRequests, err
+}
+
+// pullRequest reads activity from notes, gitlab records approvals and
+// pushed commits as system notes
+func (g *gitLabApi) pullRequest(projectUrl string, repository string, mergeRequest gitLabMergeRequestJson) (pullRequest, error) {
+	pullRequest := pullRequest{
+		repository: repository,
+		number:     mergeRequest.Iid,
+		title:      mergeRequest.Title,
+		body:       mergeRequest.Description,
+		branch:     mergeRequest.SourceBranch,
+		author:     mergeRequest.Author.Username,
+		url:        mergeRequest.WebUrl,
+		events: []pullRequestEvent{
+			{mergeRequest.Author.Username, pullRequestOpened, mergeRequest.CreatedAt},
+		},
+	}
+
+	notesUrl := fmt.Sprintf("%s/merge_requests/%d/notes", projectUrl, mergeRequest.Iid)
+
+	err := getPages(g.httpClient, notesUrl, g.headers(), func(page []json.RawMessage) (bool, error) {
+		for _, raw := range page {
+			var note gitLabNoteJson
+
+			if err := json.Unmarshal(raw, &note); err != nil {
+				return false, err
+			}
+
+			var kind string
+
+			switch {
+			case note.System && strings.HasPrefix(note.Body, "approved this merge request"):
+				kind = pullRequestApproval
+			case note.System && strings.HasPrefix(note.Body, "added ") && strings.Contains(note.Body, "commit"):
+				kind = pullRequestCommit
+			case note.System:
+				continue
+			case note.Type == "DiffNote":
+				kind = pullRequestReview
+			default:
+				kind = pullRequestComment
+			}
+
+			pullRequest.events = append(pullRequest.events, pullRequestEvent{note.Author.Username, kind, note.CreatedAt})
+		}
+
+		return true, nil
+	})
+
+	return pullRequest, err
+}
+
+func (g *gitLabApi) headers() map[string]string {
+	headers := map[string]string{}
+
+	if g.token != "" {
+		headers["PRIVATE-TOKEN"] = g.token
+	}
+
+	return headers
+}
+
+func newGitLabApi(baseUrl string, token string, client HttpClient) *gitLabApi {
+	if baseUrl == "" {
+		baseUrl = "https://gitlab.com"
+	}
+
+	return &gitLabApi{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		token:      token,
+		httpClient: client,
+	}
+}
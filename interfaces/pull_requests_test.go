@@ -0,0 +1,184 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+var pullRequestDay = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func pullRequestAt(hour, minute int) string {
+	return pullRequestDay.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute).Format(time.RFC3339)
+}
+
+// jsonRoutes answers GET requests by path with given json, anything else is 404
+func jsonRoutes(token string, tokenHeader string, routes map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(tokenHeader) != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		response, ok := routes[r.URL.EscapedPath()]
+
+		if !ok || r.URL.Query().Get("page") != "1" {
+			if ok {
+				w.Write([]byte("[]"))
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func newTestPullRequestSource(api pullRequestApi, repository string) *PullRequestSource {
+	return &PullRequestSource{
+		api:            api,
+		repositories:   []string{repository},
+		user:           "ana",
+		sessionTimeout: time.Hour,
+		firstEvent:     15 * time.Minute,
+		location:       time.UTC,
+		extractor:      NewIssueKeyExtractor(true, []string{"MAT"}, nil, time.Minute),
+	}
+}
+
+func TestGitHubPullRequestActivity(t *testing.T) {
+	server := jsonRoutes("token secret", "Authorization", map[string]interface{}{
+		"/repos/acme/app/pulls": []map[string]interface{}{
+			{"number": 7, "title": "Login form", "body": "Closes https://youtrack.example.com/issue/MAT-7", "html_url": "https://github.com/acme/app/pull/7",
+				"user": map[string]string{"login": "ivo"}, "head": map[string]string{"ref": "feature/login"}, "created_at": pullRequestAt(8, 0), "updated_at": pullRequestAt(12, 0)},
+			{"number": 8, "title": "MAT-8 Fix typo", "html_url": "https://github.com/acme/app/pull/8",
+				"user": map[string]string{"login": "ana"}, "head": map[string]string{"ref": "fix"}, "created_at": pullRequestAt(10, 0), "updated_at": pullRequestAt(10, 30)},
+			{"number": 3, "title": "Old one", "user": map[string]string{"login": "ana"}, "created_at": "2017-06-01T10:00:00Z", "updated_at": "2017-06-01T10:00:00Z"},
+		},
+		"/repos/acme/app/pulls/7/reviews": []map[string]interface{}{
+			{"user": map[string]string{"login": "ana"}, "state": "COMMENTED", "submitted_at": pullRequestAt(9, 0)},
+			{"user": map[string]string{"login": "ana"}, "state": "APPROVED", "submitted_at": pullRequestAt(9, 40)},
+			{"user": map[string]string{"login": "ana"}, "state": "PENDING"},
+		},
+		"/repos/acme/app/pulls/7/comments": []map[string]interface{}{
+			{"user": map[string]string{"login": "ana"}, "created_at": pullRequestAt(9, 20)},
+			{"user": map[string]string{"login": "ivo"}, "created_at": pullRequestAt(9, 30)},
+		},
+		"/repos/acme/app/issues/7/comments": []map[string]interface{}{},
+		"/repos/acme/app/pulls/7/commits":   []map[string]interface{}{},
+		"/repos/acme/app/pulls/8/reviews":   []map[string]interface{}{},
+		"/repos/acme/app/pulls/8/comments":  []map[string]interface{}{},
+		"/repos/acme/app/issues/8/comments": []map[string]interface{}{
+			{"user": map[string]string{"login": "ana"}, "created_at": pullRequestAt(10, 30)},
+		},
+		"/repos/acme/app/pulls/8/commits": []map[string]interface{}{
+			{"author": map[string]string{"login": "ana"}, "commit": map[string]interface{}{"author": map[string]string{"date": pullRequestAt(9, 50)}}},
+			{"author": nil, "commit": map[string]interface{}{"author": map[string]string{"date": pullRequestAt(9, 55)}}},
+		},
+	})
+	defer server.Close()
+
+	source := newTestPullRequestSource(newGitHubApi(server.URL, "secret", &OfficialHttpClientAdapter{server.Client()}), "acme/app")
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(pullRequestDay, pullRequestDay, time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatal("Expected entry per pull request", entries)
+	}
+
+	review, authored := entries[0], entries[1]
+
+	// 15 for first review, 20 to comment, 20 to approval
+	if review.Id != "github:acme/app#7" || review.IssueId != "MAT-7" || review.Duration != 55 {
+		t.Fatal("Unexpected review", review)
+	}
+
+	if review.Description != "Code review acme/app#7: Login form (review, comment, approval)" || review.Links[0] != "https://github.com/acme/app/pull/7" {
+		t.Fatal("Unexpected review description", review.Description)
+	}
+
+	// 10 since approval, 10 to open, 30 to comment
+	if authored.IssueId != "MAT-8" || authored.Duration != 50 || !strings.HasPrefix(authored.Description, "Pull request acme/app#8") {
+		t.Fatal("Unexpected authored pull request", authored)
+	}
+}
+
+func TestGitLabMergeRequestActivity(t *testing.T) {
+	server := jsonRoutes("secret", "PRIVATE-TOKEN", map[string]interface{}{
+		"/api/v4/projects/acme%2Fapp/merge_requests": []map[string]interface{}{
+			{"iid": 4, "title": "Import", "description": "", "source_branch": "feature/mat-4-import", "web_url": "https://gitlab.com/acme/app/-/merge_requests/4",
+				"author": map[string]string{"username": "ivo"}, "created_at": pullRequestAt(8, 0)},
+		},
+		"/api/v4/projects/acme%2Fapp/merge_requests/4/notes": []map[string]interface{}{
+			{"type": "DiffNote", "body": "why?", "author": map[string]string{"username": "ana"}, "created_at": pullRequestAt(13, 0)},
+			{"body": "looks fine", "author": map[string]string{"username": "ana"}, "created_at": pullRequestAt(13, 30)},
+			{"system": true, "body": "approved this merge request", "author": map[string]string{"username": "ana"}, "created_at": pullRequestAt(13, 35)},
+			{"system": true, "body": "changed the description", "author": map[string]string{"username": "ana"}, "created_at": pullRequestAt(16, 0)},
+		},
+	})
+	defer server.Close()
+
+	source := newTestPullRequestSource(newGitLabApi(server.URL, "secret", &OfficialHttpClientAdapter{server.Client()}), "acme/app")
+
+	entries, err := source.GetTimeEntries(domain.TimeEntryFilter{}, domain.NewDaysWindow(pullRequestDay, pullRequestDay, time.UTC))
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(entries) != 1 || entries[0].IssueId != "MAT-4" || entries[0].Duration != 50 || entries[0].Source != "gitlab" {
+		t.Fatal("Unexpected entries", entries)
+	}
+}
+
+func TestPullRequestApiErrors(t *testing.T) {
+	server := jsonRoutes("secret", "PRIVATE-TOKEN", map[string]interface{}{})
+	defer server.Close()
+
+	window := domain.NewDaysWindow(pullRequestDay, pullRequestDay, time.UTC)
+
+	_, err := newTestPullRequestSource(newGitLabApi(server.URL, "wrong", &OfficialHttpClientAdapter{server.Client()}), "acme/app").GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if usecases.KindOf(err) != usecases.ErrorAuthFailed {
+		t.Fatal("Expected auth error", err)
+	}
+
+	_, err = newTestPullRequestSource(newGitLabApi(server.URL, "secret", &OfficialHttpClientAdapter{server.Client()}), "acme/missing").GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+}
+
+func TestPullRequestIssueIdUsesOnlyExtractor(t *testing.T) {
+	source := newTestPullRequestSource(nil, "acme/app")
+
+	for expected, pullRequest := range map[string]pullRequest{
+		"MAT-1": {title: "Handle UTF-8 names", body: "Refs MAT-1", branch: "feature/mat-2"},
+		"MAT-2": {title: "Use SHA-256", body: "ISO-8601 dates", branch: "refs/heads/feature/mat-2-hashes"},
+		"":      {title: "Use SHA-256", branch: "feature/utf-8"},
+	} {
+		if issueId := source.issueId(&pullRequest); issueId != expected {
+			t.Fatal("Unexpected issue", pullRequest, issueId)
+		}
+	}
+}
+
+func TestPullRequestSourceNeedsUser(t *testing.T) {
+	_, err := NewPullRequestSource(githubSourceName, "", "token", []string{"acme/app"}, "", time.Hour, time.Minute, time.UTC, SimpleRegexIssueIdExtractor{})
+
+	if err == nil {
+		t.Fatal("Expected empty user to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package interfaces
+
+import (
+	"fmt"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// RoundingConfig is rounding as it is written in config
+type RoundingConfig struct {
+	Mode      string
+	Increment int
+	Minimum   int
+	DailyCap  int
+	// Projects override default rounding, by project short name
+	Projects map[string]RoundingPolicyConfig
+}
+
+// RoundingPolicyConfig overrides default rounding for a project,
+// fields it doesn't set are taken from the default
+type RoundingPolicyConfig struct {
+	Mode      string `mapstructure:"mode"`
+	Increment *int   `mapstructure:"increment"`
+	Minimum   *int   `mapstructure:"minimum"`
+}
+
+// NewDurationRounding checks rounding config, nil is returned when
+// durations are logged as they are
+func NewDurationRounding(config RoundingConfig) (*usecases.DurationRounding, error) {
+	defaultPolicy, err := roundingPolicy(config.Mode, config.Increment, config.Minimum)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rounding := &usecases.DurationRounding{
+		Default:  defaultPolicy,
+		Projects: map[string]domain.RoundingPolicy{},
+		DailyCap: int64(config.DailyCap),
+	}
+
+	for shortName, project := range config.Projects {
+		mode := project.Mode
+		increment := int(defaultPolicy.Increment)
+		minimum := int(defaultPolicy.Minimum)
+
+		if mode == "" {
+			mode = defaultPolicy.Mode
+		}
+
+		if project.Increment != nil {
+			increment = *project.Increment
+		}
+
+		if project.Minimum != nil {
+			minimum = *project.Minimum
+		}
+
+		policy, err := roundingPolicy(mode, increment, minimum)
+
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %s", shortName, err)
+		}
+
+		rounding.Projects[domain.ProjectKey(shortName)] = policy
+	}
+
+	if defaultPolicy.String() == domain.RoundNone && len(rounding.Projects) == 0 && rounding.DailyCap <= 0 {
+		return nil, nil
+	}
+
+	return rounding, nil
+}
+
+func roundingPolicy(mode string, increment int, minimum int) (domain.RoundingPolicy, error) {
+	policy := domain.RoundingPolicy{
+		Mode:      mode,
+		Increment: int64(increment),
+		Minimum:   int64(minimum),
+	}
+
+	switch mode {
+	case domain.RoundNone:
+	case domain.RoundUp, domain.RoundDown, domain.RoundNearest:
+		if increment <= 0 {
+			return policy, fmt.Errorf("increment of %s rounding must be positive, got %d", mode, increment)
+		}
+	default:
+		return policy, fmt.Errorf("unknown rounding mode %q, expected none, up, down or nearest", mode)
+	}
+
+	if minimum < 0 {
+		return policy, fmt.Errorf("minimum must not be negative, got %d", minimum)
+	}
+
+	return policy, nil
+}
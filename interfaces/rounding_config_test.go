@@ -0,0 +1,95 @@
+package interfaces
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+func TestNewDurationRounding(t *testing.T) {
+	thirty := 30
+	zero := 0
+
+	cases := map[string]struct {
+		config   RoundingConfig
+		expected *usecases.DurationRounding
+	}{
+		"nothing to round": {
+			config:   RoundingConfig{Mode: domain.RoundNone, Increment: 15},
+			expected: nil,
+		},
+		"default only": {
+			config: RoundingConfig{Mode: domain.RoundUp, Increment: 15, Minimum: 15},
+			expected: &usecases.DurationRounding{
+				Default:  domain.RoundingPolicy{Mode: domain.RoundUp, Increment: 15, Minimum: 15},
+				Projects: map[string]domain.RoundingPolicy{},
+			},
+		},
+		"daily cap without rounding": {
+			config: RoundingConfig{Mode: domain.RoundNone, DailyCap: 480},
+			expected: &usecases.DurationRounding{
+				Default:  domain.RoundingPolicy{Mode: domain.RoundNone},
+				Projects: map[string]domain.RoundingPolicy{},
+				DailyCap: 480,
+			},
+		},
+		"projects take what they don't set from default": {
+			config: RoundingConfig{
+				Mode:      domain.RoundUp,
+				Increment: 15,
+				Minimum:   15,
+				Projects: map[string]RoundingPolicyConfig{
+					"mat": {Mode: domain.RoundNearest, Increment: &thirty},
+					"sup": {Minimum: &zero},
+				},
+			},
+			expected: &usecases.DurationRounding{
+				Default: domain.RoundingPolicy{Mode: domain.RoundUp, Increment: 15, Minimum: 15},
+				Projects: map[string]domain.RoundingPolicy{
+					"MAT": {Mode: domain.RoundNearest, Increment: 30, Minimum: 15},
+					"SUP": {Mode: domain.RoundUp, Increment: 15},
+				},
+			},
+		},
+		"project rounding without default": {
+			config: RoundingConfig{
+				Mode:     domain.RoundNone,
+				Projects: map[string]RoundingPolicyConfig{"mat": {Mode: domain.RoundDown, Increment: &thirty}},
+			},
+			expected: &usecases.DurationRounding{
+				Default:  domain.RoundingPolicy{Mode: domain.RoundNone},
+				Projects: map[string]domain.RoundingPolicy{"MAT": {Mode: domain.RoundDown, Increment: 30}},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		rounding, err := NewDurationRounding(c.config)
+
+		if err != nil || !reflect.DeepEqual(rounding, c.expected) {
+			t.Fatalf("%s: unexpected rounding %+v %v", name, rounding, err)
+		}
+	}
+}
+
+func TestNewDurationRoundingRejectsInvalidConfig(t *testing.T) {
+	negative := -5
+
+	cases := map[string]RoundingConfig{
+		"unknown mode":            {Mode: "ceil", Increment: 15},
+		"empty mode":              {Increment: 15},
+		"no increment":            {Mode: domain.RoundUp},
+		"negative increment":      {Mode: domain.RoundNearest, Increment: -15},
+		"negative minimum":        {Mode: domain.RoundNone, Minimum: -1},
+		"invalid project mode":    {Mode: domain.RoundNone, Projects: map[string]RoundingPolicyConfig{"mat": {Mode: "sideways"}}},
+		"invalid project minimum": {Mode: domain.RoundUp, Increment: 15, Projects: map[string]RoundingPolicyConfig{"mat": {Minimum: &negative}}},
+	}
+
+	for name, config := range cases {
+		if _, err := NewDurationRounding(config); err == nil {
+			t.Fatal("Expected error for", name)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package interfaces
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+const togglSinkName = "toggl"
+
+// TogglClient copies work logs to toggl track as time entries, issue
+// projects are mapped to toggl projects by project short name.
+type TogglClient struct {
+	baseUrl     string
+	token       string
+	workspaceId int
+	projects    map[string]int
+	httpClient  HttpClient
+}
+
+type togglTimeEntryJson struct {
+	Id          int64    `json:"id,omitempty"`
+	WorkspaceId int      `json:"workspace_id"`
+	ProjectId   *int     `json:"project_id"`
+	Description string   `json:"description"`
+	Start       string   `json:"start"`
+	Duration    int      `json:"duration"` // seconds
+	Tags        []string `json:"tags"`
+	CreatedWith string   `json:"created_with"`
+}
+
+func (togglClient *TogglClient) Name() string {
+	return togglSinkName
+}
+
+func (togglClient *TogglClient) SaveWorkLog(workLog domain.IssueWorkLog, issue domain.Issue) (string, error) {
+	var created togglTimeEntryJson
+
+	err := togglClient.do(http.MethodPost, "/time_entries", togglClient.timeEntry(workLog, issue), &created)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(created.Id, 10), nil
+}
+
+func (togglClient *TogglClient) UpdateWorkLog(itemId string, workLog domain.IssueWorkLog, issue domain.Issue) error {
+	var updated togglTimeEntryJson
+
+	return togglClient.do(http.MethodPut, "/time_entries/"+itemId, togglClient.timeEntry(workLog, issue), &updated)
+}
+
+func (togglClient *TogglClient) DeleteWorkLog(itemId string, workLog domain.IssueWorkLog) error {
+	return togglClient.do(http.MethodDelete, "/time_entries/"+itemId, nil, nil)
+}
+
+// timeEntry describes work log by entry title, worktype becomes a tag
+func (togglClient *TogglClient) timeEntry(workLog domain.IssueWorkLog, issue domain.Issue) togglTimeEntryJson {
+	timeEntry := togglTimeEntryJson{
+		WorkspaceId: togglClient.workspaceId,
+		Description: workLog.Title,
+		Start:       workLog.Date.Format(time.RFC3339),
+		Duration:    workLog.Duration * int(time.Minute/time.Second),
+		Tags:        []string{},
+		CreatedWith: "meyougotrack",
+	}
+
+	if timeEntry.Description == "" {
+		timeEntry.Description = workLog.Description
+	}
+
+	if projectId, ok := togglClient.projects[domain.ProjectKey(issue.Project.ShortName)]; ok {
+		timeEntry.ProjectId = &projectId
+	}
+
+	if workLog.Type != "" {
+		timeEntry.Tags = append(timeEntry.Tags, workLog.Type)
+	}
+
+	return timeEntry
+}
+
+// do sends body as json to workspace path and decodes json response into
+// result, body and response are left out when nil
+func (togglClient *TogglClient) do(method string, path string, body interface{}, result interface{}) error {
+	var requestBody []byte
+
+	if body != nil {
+		var err error
+
+		if requestBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	requestUrl := fmt.Sprintf("%s/api/v9/workspaces/%d%s", togglClient.baseUrl, togglClient.workspaceId, path)
+
+	request, err := http.NewRequest(method, requestUrl, bytes.NewReader(requestBody))
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorValidation, err, "invalid toggl url %s", requestUrl)
+	}
+
+	request.Header.Add("Content-Type", "application/json")
+	request.SetBasicAuth(togglClient.token, "api_token")
+
+	response, err := togglClient.httpClient.Do(request)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "toggl unreachable")
+	}
+
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+
+	if err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "cannot read toggl response")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		// toggl explains errors in plain text
+		cause := errors.New(strings.Trim(strings.TrimSpace(string(responseBody)), `"`))
+
+		switch {
+		case response.StatusCode == http.StatusNotFound:
+			return usecases.NewError(usecases.ErrorNotFound, cause, "toggl %s not found", request.URL.Path)
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return usecases.NewError(usecases.ErrorAuthFailed, cause, "toggl refused credentials")
+		case response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests:
+			return usecases.NewError(usecases.ErrorUpstreamUnavailable, cause, "toggl failed")
+		default:
+			return usecases.NewError(usecases.ErrorValidation, cause, "toggl rejected %s", request.URL.Path)
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.Unmarshal(responseBody, result); err != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, err, "unexpected toggl response")
+	}
+
+	return nil
+}
+
+// NewTogglClient takes projects as issue project key to toggl project id
+func NewTogglClient(token string, workspaceId int, projects map[string]int) *TogglClient {
+	return &TogglClient{
+		baseUrl:     "https://api.track.toggl.com",
+		token:       token,
+		workspaceId: workspaceId,
+		projects:    projects,
+		httpClient:  &OfficialHttpClientAdapter{&http.Client{Timeout: 30 * time.Second}},
+	}
+}
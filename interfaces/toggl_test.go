@@ -0,0 +1,98 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+func newTestTogglClient(server *httptest.Server) *TogglClient {
+	client := NewTogglClient("token", 7, map[string]int{"MAT": 123})
+	client.baseUrl = server.URL
+	client.httpClient = &OfficialHttpClientAdapter{server.Client()}
+
+	return client
+}
+
+func TestTogglSaveAndUpdateWorkLog(t *testing.T) {
+	var received []togglTimeEntryJson
+	var paths []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "token" || password != "api_token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		paths = append(paths, r.Method+" "+r.URL.Path)
+
+		if r.Method == http.MethodDelete {
+			return
+		}
+
+		var timeEntry togglTimeEntryJson
+		json.NewDecoder(r.Body).Decode(&timeEntry)
+
+		received = append(received, timeEntry)
+
+		timeEntry.Id = 99
+		json.NewEncoder(w).Encode(timeEntry)
+	}))
+	defer server.Close()
+
+	client := newTestTogglClient(server)
+
+	workLog := domain.IssueWorkLog{
+		IssueId:     "MAT-1",
+		Title:       "https://youtrack.example.com/issue/MAT-1 login",
+		Description: "login form",
+		Type:        "Development",
+		Duration:    90,
+		Date:        time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	id, err := client.SaveWorkLog(workLog, domain.Issue{Id: "MAT-1", Project: domain.Project{ShortName: "MAT"}})
+
+	if err != nil || id != "99" {
+		t.Fatal("Expected time entry to be created", id, err)
+	}
+
+	created := received[0]
+
+	if created.Description != workLog.Title || created.Duration != 5400 || *created.ProjectId != 123 || created.Tags[0] != "Development" || created.WorkspaceId != 7 {
+		t.Fatalf("Unexpected time entry %+v", created)
+	}
+
+	err = client.UpdateWorkLog("99", workLog, domain.Issue{Id: "OTHER-1", Project: domain.Project{ShortName: "OTHER"}})
+
+	if err != nil || received[1].ProjectId != nil {
+		t.Fatal("Expected time entry without project to be updated", err)
+	}
+
+	if err = client.DeleteWorkLog("99", workLog); err != nil {
+		t.Fatal("Expected time entry to be deleted", err)
+	}
+
+	if paths[0] != "POST /api/v9/workspaces/7/time_entries" || paths[1] != "PUT /api/v9/workspaces/7/time_entries/99" || paths[2] != "DELETE /api/v9/workspaces/7/time_entries/99" {
+		t.Fatal("Unexpected requests", paths)
+	}
+}
+
+func TestTogglRejectsWorkLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`"Workspace needs to have a project"`))
+	}))
+	defer server.Close()
+
+	_, err := newTestTogglClient(server).SaveWorkLog(domain.IssueWorkLog{Duration: 1}, domain.Issue{})
+
+	if usecases.KindOf(err) != usecases.ErrorValidation || err.Error() != "toggl rejected /api/v9/workspaces/7/time_entries: Workspace needs to have a project" {
+		t.Fatal("Expected validation error with toggl reason", err)
+	}
+}
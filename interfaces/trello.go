@@ -1,55 +1,298 @@
 package interfaces
 
 import (
+	"net/http"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/adlio/trello"
 	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
 )
 
+const trelloSourceName = "trello"
+
+// TrelloAdlioClient is a time source counting time cards spent in doing list
 type TrelloAdlioClient struct {
-	client *trello.Client
+	client        *trello.Client
+	boardId       string
+	doingListName string
+	location      *time.Location
+	workers       int
+	// member whose time is counted, by id or username; everybody's if empty
+	member string
+}
+
+type listInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+func (t *TrelloAdlioClient) Name() string {
+	return trelloSourceName
+}
+
+// GetTimeEntries returns time cards matching the filter spent in doing list
+// (or lists from the filter) during the window, one entry per card per day.
+func (t *TrelloAdlioClient) GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error) {
+
+	board, err := t.client.GetBoard(t.boardId, trello.Defaults())
+
+	if err != nil {
+		return nil, trelloError(err, "cannot get trello board %s", t.boardId)
+	}
+
+	cardsFilter := "open"
+
+	if filter.IncludeClosed {
+		cardsFilter = "all"
+	}
+
+	cards, err := board.GetCards(trello.Arguments{
+		"filter":        cardsFilter,
+		"members":       "true",
+		"member_fields": "username,fullName",
+	})
+
+	if err != nil {
+		return nil, trelloError(err, "cannot get cards of trello board %s", t.boardId)
+	}
+
+	matcher, err := newTrelloCardMatcher(board, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cards = matcher.matching(cards)
+
+	lists := filter.Lists
+
+	if len(lists) == 0 {
+		lists = []string{t.doingListName}
+	}
+
+	cardsActions, err := t.fetchActions(cards)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []domain.TimeEntry
+
+	now := time.Now()
+
+	for index, card := range cards {
+
+		intervals := listIntervals(cardsActions[index], lists, now)
+
+		// only time while the member was on the card is theirs
+		if t.member != "" {
+			intervals = intersectIntervals(intervals, memberIntervals(card, cardsActions[index], t.member, now))
+		}
+
+		for _, day := range t.durationsPerDay(intervals, window) {
+			entry := domain.TimeEntry{
+				Id:          card.ID,
+				Source:      trelloSourceName,
+				ExternalId:  card.ID,
+				Title:       card.Name,
+				Description: card.Desc,
+				Duration:    int64(day.Duration.Minutes()),
+				Date:        day.Day,
+				Start:       day.Start,
+				End:         day.End,
+				Members:     cardMemberNames(card),
+				Labels:      cardLabelNames(card),
+				List:        currentListName(cardsActions[index]),
+			}
+
+			if card.URL != "" {
+				entry.Links = []string{card.URL}
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// fetchActions gets actions of all cards using at most t.workers concurrent
+// requests. Actions are returned in the same order as cards.
+func (t *TrelloAdlioClient) fetchActions(cards []*trello.Card) ([]trello.ActionCollection, error) {
+	actions := make([]trello.ActionCollection, len(cards))
+	errs := make([]error, len(cards))
+
+	jobs := make(chan int)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+
+	workers := t.workers
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				actions[index], errs[index] = cards[index].GetActions(trello.Arguments{
+					"filter": "all",
+				})
+
+				if errs[index] != nil {
+					stopOnce.Do(func() { close(stop) })
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for index := range cards {
+		select {
+		case jobs <- index:
+		case <-stop:
+			break dispatch
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	for index, err := range errs {
+		if err != nil {
+			return nil, trelloError(err, "cannot get actions of trello card %s", cards[index].ID)
+		}
+	}
+
+	return actions, nil
 }
 
-func (t *TrelloAdlioClient) GetAllCards(boardId string, doingListName string) []domain.TrelloCardDoingDuration {
+// durationsPerDay sums parts of intervals inside the window by calendar day
+func (t *TrelloAdlioClient) durationsPerDay(intervals []listInterval, window domain.TimeWindow) []domain.DayDuration {
+	var days []domain.DayDuration
 
-	board, _ := t.client.GetBoard(boardId, trello.Defaults())
+	dayIndex := map[int64]int{}
 
-	cards, _ := board.GetCards(trello.Defaults())
+	for _, interval := range intervals {
+		start, end, ok := window.Clip(interval.start, interval.end)
 
-	var doingCards []domain.TrelloCardDoingDuration
+		if !ok {
+			continue
+		}
 
-	for _, card := range cards {
+		for _, part := range domain.SplitByDay(start, end, t.location) {
+			index, seen := dayIndex[part.Day.Unix()]
 
-		var cardDuration time.Duration = 0
+			if !seen {
+				dayIndex[part.Day.Unix()] = len(days)
+				days = append(days, part)
+				continue
+			}
 
-		actions, _ := card.GetActions(trello.Arguments{
-			"filter": "all",
-		})
+			days[index].Duration += part.Duration
 
-		durations, _ := actions.GetListDurations()
+			if part.Start.Before(days[index].Start) {
+				days[index].Start = part.Start
+			}
 
-		for _, d := range durations {
-			if d.ListName == doingListName {
-				cardDuration += d.Duration
+			if part.End.After(days[index].End) {
+				days[index].End = part.End
 			}
 		}
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day.Before(days[j].Day)
+	})
+
+	return days
+}
+
+// listIntervals reconstructs periods card spent in any of the lists from
+// actions that created or moved it. Card still in the list is there until now.
+func listIntervals(actions trello.ActionCollection, listNames []string, now time.Time) []listInterval {
+	sort.Sort(actions)
 
-		doingCard := domain.TrelloCardDoingDuration{
-			Title:       card.Name,
-			Duration:    int64(cardDuration.Minutes()),
-			Date:        card.CreatedAt(),
-			Description: card.Desc,
+	var intervals []listInterval
+	var enteredAt time.Time
+	inList := false
+
+	for _, action := range actions {
+		if !action.DidChangeListForCard() && !action.DidCreateCard() {
+			continue
+		}
+
+		list := trello.ListAfterAction(action)
+		movedIn := list != nil && containsString(listNames, list.Name)
+
+		if inList && !movedIn {
+			intervals = append(intervals, listInterval{enteredAt, action.Date})
+		}
+
+		if !inList && movedIn {
+			enteredAt = action.Date
+		}
+
+		inList = movedIn
+	}
+
+	if inList {
+		intervals = append(intervals, listInterval{enteredAt, now})
+	}
+
+	return intervals
+}
+
+// currentListName is the list card was last created or moved in
+func currentListName(actions trello.ActionCollection) string {
+	sorted := append(trello.ActionCollection{}, actions...)
+	sort.Sort(sorted)
+
+	name := ""
+
+	for _, action := range sorted {
+		if list := trello.ListAfterAction(action); list != nil {
+			name = list.Name
 		}
+	}
 
-		doingCards = append(doingCards, doingCard)
+	return name
+}
+
+// trelloError wraps trello client error into typed error with context
+func trelloError(err error, format string, args ...interface{}) error {
+	kind := usecases.ErrorUpstreamUnavailable
+
+	switch {
+	case trello.IsNotFound(err):
+		kind = usecases.ErrorNotFound
+	case trello.IsPermissionDenied(err):
+		kind = usecases.ErrorAuthFailed
 	}
 
-	return doingCards
+	return usecases.NewError(kind, err, format, args...)
 }
 
-func NewTrelloAdlioClient(apiKey string, apiToken string, boardId string, doingListName string) *TrelloAdlioClient {
+func NewTrelloAdlioClient(apiKey string, apiToken string, boardId string, doingListName string, location *time.Location, workers int, member string) *TrelloAdlioClient {
+	client := trello.NewClient(apiKey, apiToken)
+	client.Client = &http.Client{
+		Transport: newRateLimitTransport(http.DefaultTransport),
+	}
+
 	return &TrelloAdlioClient{
-		client: trello.NewClient(apiKey, apiToken),
+		client:        client,
+		boardId:       boardId,
+		doingListName: doingListName,
+		location:      location,
+		workers:       workers,
+		member:        member,
 	}
 }
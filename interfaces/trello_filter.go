@@ -0,0 +1,116 @@
+package interfaces
+
+import (
+	"github.com/adlio/trello"
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+// trelloCardMatcher checks cards against everything from the filter that
+// can be decided without fetching card actions
+type trelloCardMatcher struct {
+	filter    domain.TimeEntryFilter
+	memberIds []string
+}
+
+func newTrelloCardMatcher(board *trello.Board, filter domain.TimeEntryFilter) (*trelloCardMatcher, error) {
+	matcher := &trelloCardMatcher{
+		filter: filter,
+	}
+
+	if len(filter.Members) == 0 {
+		return matcher, nil
+	}
+
+	members, err := board.GetMembers(trello.Defaults())
+
+	if err != nil {
+		return nil, trelloError(err, "cannot get members of trello board %s", board.ID)
+	}
+
+	// filter can name members by username, cards only know member ids
+	for _, member := range members {
+		if containsString(filter.Members, member.ID) || containsString(filter.Members, member.Username) {
+			matcher.memberIds = append(matcher.memberIds, member.ID)
+		}
+	}
+
+	return matcher, nil
+}
+
+func (m *trelloCardMatcher) matching(cards []*trello.Card) []*trello.Card {
+	var found []*trello.Card
+
+	for _, card := range cards {
+		if m.matches(card) {
+			found = append(found, card)
+		}
+	}
+
+	return found
+}
+
+func (m *trelloCardMatcher) matches(card *trello.Card) bool {
+	if card.Closed && !m.filter.IncludeClosed {
+		return false
+	}
+
+	if len(m.filter.Labels) > 0 && !m.hasLabel(card) {
+		return false
+	}
+
+	if len(m.filter.Members) > 0 && !containsAny(card.IDMembers, m.memberIds) {
+		return false
+	}
+
+	return m.dueMatches(card)
+}
+
+func (m *trelloCardMatcher) hasLabel(card *trello.Card) bool {
+	for _, label := range card.Labels {
+		if containsString(m.filter.Labels, label.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *trelloCardMatcher) dueMatches(card *trello.Card) bool {
+	if m.filter.DueAfter.IsZero() && m.filter.DueBefore.IsZero() {
+		return true
+	}
+
+	if card.Due == nil {
+		return false
+	}
+
+	if !m.filter.DueAfter.IsZero() && card.Due.Before(m.filter.DueAfter) {
+		return false
+	}
+
+	if !m.filter.DueBefore.IsZero() && !card.Due.Before(m.filter.DueBefore) {
+		return false
+	}
+
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if containsString(values, candidate) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package interfaces
+
+import (
+	"sort"
+	"time"
+
+	"github.com/adlio/trello"
+)
+
+// memberIntervals reconstructs periods member was assigned to the card.
+// Actions are walked backwards from the current card members, so members
+// assigned before the history starts are counted from the beginning.
+func memberIntervals(card *trello.Card, actions trello.ActionCollection, member string, now time.Time) []listInterval {
+	sort.Sort(actions)
+
+	var intervals []listInterval
+
+	assigned := cardHasMember(card, member)
+	end := now
+
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := actions[i]
+
+		if action.Member == nil || !isMember(action.Member, member) {
+			continue
+		}
+
+		switch action.Type {
+		case "addMemberToCard":
+			if assigned {
+				intervals = append(intervals, listInterval{action.Date, end})
+			}
+			assigned = false
+		case "removeMemberFromCard":
+			if !assigned {
+				end = action.Date
+			}
+			assigned = true
+		}
+	}
+
+	if assigned {
+		intervals = append(intervals, listInterval{time.Time{}, end})
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start.Before(intervals[j].start)
+	})
+
+	return intervals
+}
+
+// intersectIntervals returns periods covered by both a and b,
+// each of them has to be sorted and without overlaps
+func intersectIntervals(a, b []listInterval) []listInterval {
+	var intersection []listInterval
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start := a[i].start
+		if b[j].start.After(start) {
+			start = b[j].start
+		}
+
+		end := a[i].end
+		if b[j].end.Before(end) {
+			end = b[j].end
+		}
+
+		if start.Before(end) {
+			intersection = append(intersection, listInterval{start, end})
+		}
+
+		if a[i].end.Before(b[j].end) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return intersection
+}
+
+func cardHasMember(card *trello.Card, member string) bool {
+	if containsString(card.IDMembers, member) {
+		return true
+	}
+
+	for _, m := range card.Members {
+		if isMember(m, member) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func cardMemberNames(card *trello.Card) []string {
+	var names []string
+
+	for _, m := range card.Members {
+		names = append(names, m.Username)
+	}
+
+	return names
+}
+
+func cardLabelNames(card *trello.Card) []string {
+	var names []string
+
+	for _, label := range card.Labels {
+		names = append(names, label.Name)
+	}
+
+	return names
+}
+
+// isMember matches member by id or username
+func isMember(m *trello.Member, member string) bool {
+	return m.ID == member || m.Username == member
+}
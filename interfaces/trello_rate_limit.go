@@ -0,0 +1,106 @@
+package interfaces
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitMaxRetries  = 5
+	rateLimitBaseBackoff = 500 * time.Millisecond
+)
+
+// trello tells how many requests are left in the current interval
+// for both api key and token
+var rateLimitHeaders = []struct {
+	remaining string
+	interval  string
+}{
+	{"X-Rate-Limit-Api-Token-Remaining", "X-Rate-Limit-Api-Token-Interval-Ms"},
+	{"X-Rate-Limit-Api-Key-Remaining", "X-Rate-Limit-Api-Key-Interval-Ms"},
+}
+
+// rateLimitTransport holds back all requests once trello says the limit is
+// used up and retries requests rejected with 429 using exponential backoff.
+type rateLimitTransport struct {
+	next  http.RoundTripper
+	sleep func(time.Duration)
+
+	mutex      sync.Mutex
+	pauseUntil time.Time
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := rateLimitBaseBackoff
+
+	for attempt := 0; ; attempt++ {
+		t.waitForPause()
+
+		response, err := t.next.RoundTrip(req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.pauseIfExhausted(response)
+
+		if response.StatusCode != http.StatusTooManyRequests || attempt == rateLimitMaxRetries || req.Body != nil {
+			return response, nil
+		}
+
+		response.Body.Close()
+
+		wait := backoff
+
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+
+		t.sleep(wait)
+
+		backoff *= 2
+	}
+}
+
+func (t *rateLimitTransport) waitForPause() {
+	t.mutex.Lock()
+	wait := time.Until(t.pauseUntil)
+	t.mutex.Unlock()
+
+	if wait > 0 {
+		t.sleep(wait)
+	}
+}
+
+func (t *rateLimitTransport) pauseIfExhausted(response *http.Response) {
+	for _, header := range rateLimitHeaders {
+		remaining, err := strconv.Atoi(response.Header.Get(header.remaining))
+
+		if err != nil || remaining > 0 {
+			continue
+		}
+
+		intervalMs, err := strconv.Atoi(response.Header.Get(header.interval))
+
+		if err != nil {
+			continue
+		}
+
+		until := time.Now().Add(time.Duration(intervalMs) * time.Millisecond)
+
+		t.mutex.Lock()
+		if until.After(t.pauseUntil) {
+			t.pauseUntil = until
+		}
+		t.mutex.Unlock()
+	}
+}
+
+func newRateLimitTransport(next http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		next:  next,
+		sleep: time.Sleep,
+	}
+}
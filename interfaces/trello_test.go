@@ -0,0 +1,504 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adlio/trello"
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+// fakeTrello serves a single board with given cards and their actions,
+// every actions request takes latency to answer
+type fakeTrello struct {
+	cards   []*trello.Card
+	members []*trello.Member
+	actions map[string][]*trello.Action
+	latency func(cardId string) time.Duration
+}
+
+func (f *fakeTrello) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var response interface{}
+
+	switch path := strings.TrimPrefix(r.URL.Path, "/"); {
+	case path == "boards/board1":
+		response = trello.Board{ID: "board1"}
+	case path == "boards/board1/cards":
+		var cards []*trello.Card
+		for _, card := range f.cards {
+			if !card.Closed || r.URL.Query().Get("filter") == "all" {
+				cards = append(cards, card)
+			}
+		}
+		response = cards
+	case path == "boards/board1/members":
+		response = f.members
+	case strings.HasPrefix(path, "cards/") && strings.HasSuffix(path, "/actions"):
+		cardId := strings.TrimSuffix(strings.TrimPrefix(path, "cards/"), "/actions")
+		if f.latency != nil {
+			time.Sleep(f.latency(cardId))
+		}
+		response = f.actions[cardId]
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func moveCardAction(date time.Time, from, to string) *trello.Action {
+	return &trello.Action{
+		Type: "updateCard",
+		Date: date,
+		Data: &trello.ActionData{
+			ListBefore: &trello.List{ID: from, Name: from},
+			ListAfter:  &trello.List{ID: to, Name: to},
+		},
+	}
+}
+
+func createCardAction(date time.Time, list string) *trello.Action {
+	return &trello.Action{
+		Type: "createCard",
+		Date: date,
+		Data: &trello.ActionData{
+			List: &trello.List{ID: list, Name: list},
+		},
+	}
+}
+
+func newTestTrelloClient(server *httptest.Server) *TrelloAdlioClient {
+	client := trello.NewClient("key", "token")
+	client.BaseURL = server.URL
+
+	return &TrelloAdlioClient{
+		client:        client,
+		boardId:       "board1",
+		doingListName: "Doing",
+		location:      time.UTC,
+		workers:       4,
+	}
+}
+
+// newFakeTrelloBoard has cards which were all in doing list
+// for an hour on 1.1.2018.
+func newFakeTrelloBoard(numberOfCards int) *fakeTrello {
+	board := &fakeTrello{
+		actions: map[string][]*trello.Action{},
+	}
+
+	for i := 0; i < numberOfCards; i++ {
+		cardId := fmt.Sprintf("card%d", i)
+
+		board.cards = append(board.cards, &trello.Card{ID: cardId, Name: cardId})
+		board.actions[cardId] = []*trello.Action{
+			createCardAction(time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC), "Doing"),
+			moveCardAction(time.Date(2018, 1, 1, 11, 0, 0, 0, time.UTC), "Doing", "Done"),
+		}
+	}
+
+	return board
+}
+
+func TestGetTimeEntriesWithBadToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("invalid token"))
+	}))
+	defer server.Close()
+
+	cards, err := newTestTrelloClient(server).GetTimeEntries(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if usecases.KindOf(err) != usecases.ErrorAuthFailed {
+		t.Fatal("Expected auth failed error", err)
+	}
+
+	if cards != nil {
+		t.Fatal("Expected no cards")
+	}
+}
+
+func TestGetTimeEntriesWithMissingBoard(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := newTestTrelloClient(server).GetTimeEntries(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+}
+
+func TestGetTimeEntriesSplitsDoingTimeByDay(t *testing.T) {
+	day := func(d, h int) time.Time {
+		return time.Date(2018, 1, d, h, 0, 0, 0, time.UTC)
+	}
+
+	server := httptest.NewServer(&fakeTrello{
+		cards: []*trello.Card{
+			{ID: "card1", Name: "card one"},
+		},
+		actions: map[string][]*trello.Action{
+			"card1": {
+				createCardAction(day(1, 8), "Todo"),
+				moveCardAction(day(1, 22), "Todo", "Doing"),
+				moveCardAction(day(2, 3), "Doing", "Review"),
+				moveCardAction(day(3, 10), "Review", "Doing"),
+				moveCardAction(day(3, 12), "Doing", "Done"),
+				moveCardAction(day(5, 10), "Done", "Doing"),
+				moveCardAction(day(5, 11), "Doing", "Done"),
+			},
+		},
+	})
+	defer server.Close()
+
+	window := domain.NewDaysWindow(day(1, 0), day(3, 0), time.UTC)
+
+	cards, err := newTestTrelloClient(server).GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		date     time.Time
+		duration int64
+	}{
+		{day(1, 0), 120},
+		{day(2, 0), 180},
+		{day(3, 0), 120},
+	}
+
+	if len(cards) != len(expected) {
+		t.Fatalf("Expected %d days, got %+v", len(expected), cards)
+	}
+
+	for i, e := range expected {
+		if !cards[i].Date.Equal(e.date) || cards[i].Duration != e.duration || cards[i].Id != "card1" {
+			t.Fatalf("Unexpected card for day %d: %+v", i, cards[i])
+		}
+	}
+}
+
+func TestGetTimeEntriesKeepsCardOrderWhenFetchingConcurrently(t *testing.T) {
+	board := newFakeTrelloBoard(20)
+	// earlier cards answer slower so they finish last
+	board.latency = func(cardId string) time.Duration {
+		var index int
+		fmt.Sscanf(cardId, "card%d", &index)
+		return time.Duration(20-index) * time.Millisecond
+	}
+
+	server := httptest.NewServer(board)
+	defer server.Close()
+
+	window := domain.NewDaysWindow(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+
+	cards, err := newTestTrelloClient(server).GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cards) != 20 {
+		t.Fatal("Expected all cards", len(cards))
+	}
+
+	for i, card := range cards {
+		if card.Id != fmt.Sprintf("card%d", i) {
+			t.Fatal("Cards out of order", i, card.Id)
+		}
+	}
+}
+
+func TestGetTimeEntriesStopsOnFailedCard(t *testing.T) {
+	board := newFakeTrelloBoard(10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cards/card3/actions" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		board.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	_, err := newTestTrelloClient(server).GetTimeEntries(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err == nil || !strings.Contains(err.Error(), "card3") {
+		t.Fatal("Expected error mentioning failed card", err)
+	}
+}
+
+func TestRateLimitTransportRetriesTooManyRequests(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var slept []time.Duration
+	transport := newRateLimitTransport(http.DefaultTransport)
+	transport.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+	}
+
+	response, err := (&http.Client{Transport: transport}).Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatal("Expected request to be retried until it succeeds", response.StatusCode, calls)
+	}
+
+	if len(slept) != 2 || slept[1] != 2*slept[0] {
+		t.Fatal("Expected exponential backoff", slept)
+	}
+}
+
+func TestRateLimitTransportPausesWhenLimitIsUsedUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Rate-Limit-Api-Token-Remaining", "0")
+		w.Header().Set("X-Rate-Limit-Api-Token-Interval-Ms", "10000")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var slept time.Duration
+	transport := newRateLimitTransport(http.DefaultTransport)
+	transport.sleep = func(d time.Duration) {
+		slept += d
+	}
+
+	client := &http.Client{Transport: transport}
+	client.Get(server.URL)
+
+	if slept != 0 {
+		t.Fatal("First request should not wait")
+	}
+
+	client.Get(server.URL)
+
+	if slept < 9*time.Second {
+		t.Fatal("Second request should wait for the interval", slept)
+	}
+}
+
+func benchmarkGetTimeEntries(b *testing.B, workers int) {
+	board := newFakeTrelloBoard(50)
+	board.latency = func(cardId string) time.Duration {
+		return 2 * time.Millisecond
+	}
+
+	server := httptest.NewServer(board)
+	defer server.Close()
+
+	client := newTestTrelloClient(server)
+	client.workers = workers
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := client.GetTimeEntries(domain.TimeEntryFilter{}, domain.TimeWindow{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetTimeEntriesSequential(b *testing.B) {
+	benchmarkGetTimeEntries(b, 1)
+}
+
+func BenchmarkGetTimeEntriesWithWorkerPool(b *testing.B) {
+	benchmarkGetTimeEntries(b, 8)
+}
+
+func TestGetTimeEntriesAppliesFilter(t *testing.T) {
+	due := time.Date(2018, 1, 10, 12, 0, 0, 0, time.UTC)
+	board := newFakeTrelloBoard(6)
+	board.members = []*trello.Member{
+		{ID: "member1", Username: "jdoe"},
+		{ID: "member2", Username: "other"},
+	}
+	board.cards[0].IDMembers = []string{"member1"}
+	board.cards[0].Labels = []*trello.Label{{Name: "backend"}}
+	board.cards[0].Due = &due
+	// closed
+	board.cards[1].Closed = true
+	board.cards[1].IDMembers = []string{"member1"}
+	board.cards[1].Labels = []*trello.Label{{Name: "backend"}}
+	board.cards[1].Due = &due
+	// wrong member
+	board.cards[2].IDMembers = []string{"member2"}
+	board.cards[2].Labels = []*trello.Label{{Name: "backend"}}
+	board.cards[2].Due = &due
+	// wrong label
+	board.cards[3].IDMembers = []string{"member1"}
+	board.cards[3].Labels = []*trello.Label{{Name: "frontend"}}
+	board.cards[3].Due = &due
+	// no due date
+	board.cards[4].IDMembers = []string{"member1"}
+	board.cards[4].Labels = []*trello.Label{{Name: "backend"}}
+
+	server := httptest.NewServer(board)
+	defer server.Close()
+
+	filter := domain.TimeEntryFilter{
+		Labels:    []string{"backend"},
+		Members:   []string{"jdoe"},
+		DueAfter:  time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC),
+		DueBefore: time.Date(2018, 1, 11, 0, 0, 0, 0, time.UTC),
+	}
+
+	cards, err := newTestTrelloClient(server).GetTimeEntries(filter, domain.TimeWindow{To: time.Now()})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cards) != 1 || cards[0].Id != "card0" {
+		t.Fatalf("Expected only first card, got %+v", cards)
+	}
+
+	if len(cards[0].Labels) != 1 || cards[0].Labels[0] != "backend" || cards[0].List != "Done" {
+		t.Fatalf("Expected labels and current list of the card, got %+v", cards[0])
+	}
+}
+
+func TestGetTimeEntriesCountsOnlyFilteredLists(t *testing.T) {
+	day := func(h int) time.Time {
+		return time.Date(2018, 1, 1, h, 0, 0, 0, time.UTC)
+	}
+
+	server := httptest.NewServer(&fakeTrello{
+		cards: []*trello.Card{
+			{ID: "card1", Name: "card one"},
+			{ID: "card2", Name: "never in doing"},
+		},
+		actions: map[string][]*trello.Action{
+			"card1": {
+				createCardAction(day(8), "Doing"),
+				moveCardAction(day(9), "Doing", "Review"),
+				moveCardAction(day(11), "Review", "Done"),
+			},
+			"card2": {
+				createCardAction(day(8), "Todo"),
+			},
+		},
+	})
+	defer server.Close()
+
+	window := domain.NewDaysWindow(day(0), day(0), time.UTC)
+	filter := domain.TimeEntryFilter{Lists: []string{"Doing", "Review"}}
+
+	cards, err := newTestTrelloClient(server).GetTimeEntries(filter, window)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cards) != 1 || cards[0].Duration != 180 {
+		t.Fatalf("Expected time from both lists on single card, got %+v", cards)
+	}
+}
+
+func memberAction(date time.Time, actionType string, username string) *trello.Action {
+	return &trello.Action{
+		Type:   actionType,
+		Date:   date,
+		Member: &trello.Member{ID: "id-" + username, Username: username},
+		Data:   &trello.ActionData{},
+	}
+}
+
+func TestGetTimeEntriesCountsOnlyTimeMemberWasAssigned(t *testing.T) {
+	day := func(h int) time.Time {
+		return time.Date(2018, 1, 1, h, 0, 0, 0, time.UTC)
+	}
+
+	server := httptest.NewServer(&fakeTrello{
+		cards: []*trello.Card{
+			{ID: "card1", Name: "joined later", Members: []*trello.Member{{ID: "id-jdoe", Username: "jdoe"}, {ID: "id-other", Username: "other"}}},
+			{ID: "card2", Name: "left early", Members: []*trello.Member{{ID: "id-other", Username: "other"}}},
+			{ID: "card3", Name: "never assigned", Members: []*trello.Member{{ID: "id-other", Username: "other"}}},
+			{ID: "card4", Name: "always assigned", IDMembers: []string{"id-jdoe"}, Members: []*trello.Member{{ID: "id-jdoe", Username: "jdoe"}}},
+		},
+		actions: map[string][]*trello.Action{
+			"card1": {
+				createCardAction(day(8), "Doing"),
+				memberAction(day(9), "addMemberToCard", "jdoe"),
+				moveCardAction(day(12), "Doing", "Done"),
+			},
+			"card2": {
+				createCardAction(day(8), "Doing"),
+				memberAction(day(10), "removeMemberFromCard", "jdoe"),
+				moveCardAction(day(12), "Doing", "Done"),
+			},
+			"card3": {
+				createCardAction(day(8), "Doing"),
+				moveCardAction(day(12), "Doing", "Done"),
+			},
+			"card4": {
+				createCardAction(day(8), "Doing"),
+				moveCardAction(day(12), "Doing", "Done"),
+			},
+		},
+	})
+	defer server.Close()
+
+	client := newTestTrelloClient(server)
+	client.member = "jdoe"
+
+	window := domain.NewDaysWindow(day(0), day(0), time.UTC)
+
+	cards, err := client.GetTimeEntries(domain.TimeEntryFilter{}, window)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int64{
+		"card1": 180,
+		"card2": 120,
+		"card4": 240,
+	}
+
+	if len(cards) != len(expected) {
+		t.Fatalf("Unexpected cards %+v", cards)
+	}
+
+	for _, card := range cards {
+		if expected[card.Id] != card.Duration {
+			t.Fatal("Unexpected duration", card.Id, card.Duration)
+		}
+	}
+
+	if len(cards[0].Members) != 2 || cards[0].Members[0] != "jdoe" {
+		t.Fatal("Expected card members", cards[0].Members)
+	}
+}
+
+func TestCurrentListNameDoesNotDependOnActionOrder(t *testing.T) {
+	actions := trello.ActionCollection{
+		moveCardAction(time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC), "Review", "Done"),
+		createCardAction(time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC), "Doing"),
+		moveCardAction(time.Date(2018, 1, 1, 11, 0, 0, 0, time.UTC), "Doing", "Review"),
+	}
+
+	if list := currentListName(actions); list != "Done" {
+		t.Fatal("Unexpected list", list)
+	}
+}
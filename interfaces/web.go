@@ -8,16 +8,24 @@ import (
 
 	"time"
 
-	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
 
+	"github.com/vizualni/meyougotrack/domain"
 	"github.com/vizualni/meyougotrack/usecases"
 )
 
 type Web struct {
 	timeLogger usecases.TimeLogger
+	location   *time.Location
 }
 
+const queryDateFormat = "2006-01-02"
+
 type saveTimeJson struct {
+	EntryId     string    `json:"id"`
+	IssueId     string    `json:"issue_id"`
 	Url         string    `json:"title"`
 	Duration    int       `json:"duration"`
 	Description string    `json:"description"`
@@ -25,28 +33,171 @@ type saveTimeJson struct {
 	Worktype    string    `json:"worktype"`
 }
 
-func (web Web) GetLoggableItems(boardId, listDoingName string) http.HandlerFunc {
+type saveTimeResponseJson struct {
+	Results []usecases.SaveResult `json:"results"`
+	Error   *errorJson            `json:"error,omitempty"`
+}
+
+type errorEnvelopeJson struct {
+	Error *errorJson `json:"error"`
+}
+
+type errorJson struct {
+	Kind    usecases.ErrorKind `json:"kind"`
+	Message string             `json:"message"`
+}
+
+func newErrorJson(err error) *errorJson {
+	return &errorJson{
+		Kind:    usecases.KindOf(err),
+		Message: err.Error(),
+	}
+}
+
+func (web Web) GetLoggableItems(defaultFilter domain.TimeEntryFilter) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		linkedCards := web.timeLogger.GetTrelloYouTrackCards(boardId, listDoingName)
-		b, e := json.Marshal(linkedCards)
-		fmt.Println(e)
-		w.Write(b)
+		window, err := web.parseWindow(req)
+
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		filter, err := web.parseFilter(req, defaultFilter)
+
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		items, err := web.timeLogger.GetLoggableItems(filter, window)
+
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJson(w, http.StatusOK, items)
 	})
 }
 
+// parseWindow reads from and to days (both included) from the query,
+// each of them defaults to today.
+func (web Web) parseWindow(req *http.Request) (domain.TimeWindow, error) {
+	return daysWindow(req.URL.Query().Get("from"), req.URL.Query().Get("to"), web.location)
+}
+
+// daysWindow covers from and to days (both included), empty days are today
+func daysWindow(fromDay string, toDay string, location *time.Location) (domain.TimeWindow, error) {
+	today := time.Now().In(location)
+
+	from, err := parseDate(fromDay, today, location)
+
+	if err != nil {
+		return domain.TimeWindow{}, usecases.NewError(usecases.ErrorValidation, err, "invalid from date")
+	}
+
+	to, err := parseDate(toDay, today, location)
+
+	if err != nil {
+		return domain.TimeWindow{}, usecases.NewError(usecases.ErrorValidation, err, "invalid to date")
+	}
+
+	if to.Before(from) {
+		return domain.TimeWindow{}, usecases.NewError(usecases.ErrorValidation, nil, "to date is before from date")
+	}
+
+	return domain.NewDaysWindow(from, to, location), nil
+}
+
+// parseFilter overrides parts of default filter given in the query:
+// lists, labels and members are comma separated, include_closed is 0 or 1
+// and due_after, due_before are days (both included).
+func (web Web) parseFilter(req *http.Request, filter domain.TimeEntryFilter) (domain.TimeEntryFilter, error) {
+	query := req.URL.Query()
+
+	if lists := query.Get("lists"); lists != "" {
+		filter.Lists = splitQueryList(lists)
+	}
+
+	if labels := query.Get("labels"); labels != "" {
+		filter.Labels = splitQueryList(labels)
+	}
+
+	if members := query.Get("members"); members != "" {
+		filter.Members = splitQueryList(members)
+	}
+
+	if includeClosed := query.Get("include_closed"); includeClosed != "" {
+		filter.IncludeClosed = includeClosed == "1" || includeClosed == "true"
+	}
+
+	if dueAfter := query.Get("due_after"); dueAfter != "" {
+		day, err := time.ParseInLocation(queryDateFormat, dueAfter, web.location)
+
+		if err != nil {
+			return filter, usecases.NewError(usecases.ErrorValidation, err, "invalid due_after date")
+		}
+
+		filter.DueAfter = day
+	}
+
+	if dueBefore := query.Get("due_before"); dueBefore != "" {
+		day, err := time.ParseInLocation(queryDateFormat, dueBefore, web.location)
+
+		if err != nil {
+			return filter, usecases.NewError(usecases.ErrorValidation, err, "invalid due_before date")
+		}
+
+		filter.DueBefore = day.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+func splitQueryList(value string) []string {
+	var values []string
+
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+func parseDate(value string, defaultDate time.Time, location *time.Location) (time.Time, error) {
+	if value == "" {
+		return defaultDate, nil
+	}
+
+	return time.ParseInLocation(queryDateFormat, value, location)
+}
+
 func (web Web) Save() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var jsonLogs []saveTimeJson
 
-		body, _ := ioutil.ReadAll(r.Body)
+		body, err := ioutil.ReadAll(r.Body)
 
-		json.Unmarshal(body, &jsonLogs)
+		if err != nil {
+			writeError(w, usecases.NewError(usecases.ErrorValidation, err, "cannot read request"))
+			return
+		}
+
+		if err = json.Unmarshal(body, &jsonLogs); err != nil {
+			writeError(w, usecases.NewError(usecases.ErrorValidation, err, "expected list of logs"))
+			return
+		}
 
 		var logs []usecases.SaveTimeLog
 
 		for _, log := range jsonLogs {
 
 			logs = append(logs, usecases.SaveTimeLog{
+				EntryId:     log.EntryId,
+				IssueId:     log.IssueId,
 				WorkType:    log.Worktype,
 				Title:       log.Url,
 				Duration:    log.Duration,
@@ -55,17 +206,181 @@ func (web Web) Save() http.HandlerFunc {
 			})
 		}
 
-		err := web.timeLogger.SaveWorklogs(logs)
+		var results []usecases.SaveResult
+
+		// dry run returns requests that would be sent instead of sending them
+		if dryRun := r.URL.Query().Get("dry_run"); dryRun == "1" || dryRun == "true" {
+			results, err = web.timeLogger.PreviewWorklogs(logs)
+		} else {
+			results, err = web.timeLogger.SaveWorklogs(logs)
+		}
+
+		response := saveTimeResponseJson{
+			Results: results,
+		}
+
+		if err != nil {
+			response.Error = newErrorJson(err)
+		}
+
+		writeJson(w, saveResultsStatusCode(results), response)
+	})
+}
+
+// saveResultsStatusCode is 200 when nothing failed, 207 when only some logs
+// failed and an error code when none of them made it.
+func saveResultsStatusCode(results []usecases.SaveResult) int {
+	failed := 0
+	rejected := false
+
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+		if result.Rejected() {
+			rejected = true
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return http.StatusOK
+	case failed < len(results):
+		return http.StatusMultiStatus
+	case rejected:
+		return http.StatusBadGateway
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+func errorStatusCode(kind usecases.ErrorKind) int {
+	switch kind {
+	case usecases.ErrorNotFound:
+		return http.StatusNotFound
+	case usecases.ErrorValidation:
+		return http.StatusBadRequest
+	case usecases.ErrorAuthFailed:
+		return http.StatusUnauthorized
+	case usecases.ErrorUpstreamUnavailable:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := errorStatusCode(usecases.KindOf(err))
+
+	if status == http.StatusInternalServerError {
+		log.Println(err)
+	}
+
+	writeJson(w, status, errorEnvelopeJson{newErrorJson(err)})
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		log.Println(err)
+		status = http.StatusInternalServerError
+		b = []byte(`{"error":{"kind":"internal","message":"cannot encode response"}}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// CacheStats shows how well youtrack cache is doing
+func (web Web) CacheStats(cache *CachingIssueRepository) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, cache.Stats())
+	})
+}
+
+type workTypesJson struct {
+	IssueId   string   `json:"issue_id"`
+	WorkTypes []string `json:"worktypes"` // empty when issue accepts any
+}
+
+// WorkTypes lists work types issue given by query accepts, e.g. ?issue=MAT-1
+func (web Web) WorkTypes() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issueId := r.URL.Query().Get("issue")
+
+		workTypes, err := web.timeLogger.WorkTypes(issueId)
 
 		if err != nil {
-			fmt.Println(err)
+			writeError(w, err)
+			return
 		}
 
+		if workTypes == nil {
+			workTypes = []string{}
+		}
+
+		writeJson(w, http.StatusOK, workTypesJson{
+			IssueId:   issueId,
+			WorkTypes: workTypes,
+		})
+	})
+}
+
+// issueRulesExplanationJson tells which rule would link an entry to an issue
+type issueRulesExplanationJson struct {
+	Mapping *usecases.IssueMapping `json:"mapping"`
+	Rules   []IssueRuleExplanation `json:"rules"`
+}
+
+// ExplainIssueRules checks entry given by query, e.g. ?title=Standup&source=ics,
+// against issue rules. Labels are comma separated.
+func (web Web) ExplainIssueRules(rules *IssueRules) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rules == nil {
+			writeError(w, usecases.NewError(usecases.ErrorNotFound, nil, "issue rules are not configured"))
+			return
+		}
+
+		query := r.URL.Query()
+
+		entry := domain.TimeEntry{
+			Title:  query.Get("title"),
+			Source: query.Get("source"),
+			List:   query.Get("list"),
+			Date:   time.Now().In(web.location),
+		}
+
+		entry.Labels = splitQueryList(query.Get("labels"))
+
+		mapping, explanations := rules.Explain(entry)
+
+		writeJson(w, http.StatusOK, issueRulesExplanationJson{
+			Mapping: mapping,
+			Rules:   explanations,
+		})
+	})
+}
+
+// RecoverPanics turns panic in any handler into internal error response
+// instead of dropping the connection.
+func RecoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("panic serving %s: %v\n%s", r.URL.Path, p, debug.Stack())
+				writeError(w, usecases.NewError(usecases.ErrorInternal, nil, "internal error"))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
 	})
 }
 
-func NewWeb(t usecases.TimeLogger) *Web {
+func NewWeb(t usecases.TimeLogger, location *time.Location) *Web {
 	return &Web{
 		timeLogger: t,
+		location:   location,
 	}
 }
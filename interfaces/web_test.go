@@ -0,0 +1,258 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
+)
+
+type timeLoggerMock struct {
+	getCards        func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error)
+	saveWorklogs    func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error)
+	previewWorklogs func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error)
+	workTypes       func(issueId string) ([]string, error)
+}
+
+func (m *timeLoggerMock) GetLoggableItems(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+	return m.getCards(filter, window)
+}
+
+func (m *timeLoggerMock) SaveWorklogs(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+	return m.saveWorklogs(logs)
+}
+
+func (m *timeLoggerMock) PreviewWorklogs(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+	return m.previewWorklogs(logs)
+}
+
+func (m *timeLoggerMock) WorkTypes(issueId string) ([]string, error) {
+	return m.workTypes(issueId)
+}
+
+func decodeErrorEnvelope(t *testing.T, recorder *httptest.ResponseRecorder) errorJson {
+	var envelope struct {
+		Error errorJson `json:"error"`
+	}
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+		t.Fatal("Response is not json", recorder.Body.String())
+	}
+
+	return envelope.Error
+}
+
+func TestGetLoggableItemsMapsErrorKindToStatus(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			return nil, usecases.NewError(usecases.ErrorAuthFailed, nil, "youtrack refused credentials")
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.GetLoggableItems(domain.TimeEntryFilter{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/get-time", nil))
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+
+	if decodeErrorEnvelope(t, recorder).Kind != usecases.ErrorAuthFailed {
+		t.Fatal("Unexpected error kind", recorder.Body.String())
+	}
+}
+
+func TestGetLoggableItemsParsesDateRange(t *testing.T) {
+	var requestedWindow domain.TimeWindow
+	web := NewWeb(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			requestedWindow = window
+			return nil, nil
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.GetLoggableItems(domain.TimeEntryFilter{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/get-time?from=2018-01-01&to=2018-01-07", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+
+	expected := domain.TimeWindow{
+		From: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2018, 1, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	if requestedWindow != expected {
+		t.Fatal("Unexpected window", requestedWindow)
+	}
+}
+
+func TestGetLoggableItemsOverridesDefaultFilter(t *testing.T) {
+	var requestedFilter domain.TimeEntryFilter
+	web := NewWeb(&timeLoggerMock{
+		getCards: func(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]usecases.LoggableItem, error) {
+			requestedFilter = filter
+			return nil, nil
+		},
+	}, time.UTC)
+
+	defaultFilter := domain.TimeEntryFilter{
+		Lists:  []string{"Doing"},
+		Labels: []string{"backend"},
+	}
+
+	recorder := httptest.NewRecorder()
+	web.GetLoggableItems(defaultFilter).ServeHTTP(recorder, httptest.NewRequest("GET", "/get-time?lists=Doing,Review&members=jdoe&due_before=2018-01-07", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+
+	if len(requestedFilter.Lists) != 2 || requestedFilter.Lists[1] != "Review" {
+		t.Fatal("Expected lists from query", requestedFilter.Lists)
+	}
+
+	if len(requestedFilter.Labels) != 1 || requestedFilter.Labels[0] != "backend" {
+		t.Fatal("Expected labels from config", requestedFilter.Labels)
+	}
+
+	if len(requestedFilter.Members) != 1 || requestedFilter.Members[0] != "jdoe" {
+		t.Fatal("Expected members from query", requestedFilter.Members)
+	}
+
+	if !requestedFilter.DueBefore.Equal(time.Date(2018, 1, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expected due before to include whole day", requestedFilter.DueBefore)
+	}
+}
+
+func TestGetLoggableItemsWithInvalidDate(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.GetLoggableItems(domain.TimeEntryFilter{}).ServeHTTP(recorder, httptest.NewRequest("GET", "/get-time?from=yesterday", nil))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+}
+
+func TestSaveWithInvalidJson(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			t.Fatal("Nothing should be saved")
+			return nil, nil
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.Save().ServeHTTP(recorder, httptest.NewRequest("POST", "/save-time", strings.NewReader("{not json")))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+
+	if decodeErrorEnvelope(t, recorder).Kind != usecases.ErrorValidation {
+		t.Fatal("Unexpected error kind", recorder.Body.String())
+	}
+}
+
+func TestSaveWithPartialFailure(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			results := []usecases.SaveResult{
+				{Status: usecases.StatusSaved},
+				{Status: usecases.StatusRejected, Reason: "nope"},
+			}
+			return results, usecases.WorkLogsRejected{Results: results}
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.Save().ServeHTTP(recorder, httptest.NewRequest("POST", "/save-time", strings.NewReader("[]")))
+
+	if recorder.Code != http.StatusMultiStatus {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+}
+
+func TestSaveWithDryRunOnlyPreviews(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{
+		saveWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			t.Fatal("Dry run should not save")
+			return nil, nil
+		},
+		previewWorklogs: func(logs []usecases.SaveTimeLog) ([]usecases.SaveResult, error) {
+			results := []usecases.SaveResult{
+				{Status: usecases.StatusInvalid, Reason: "unknown work type"},
+			}
+			return results, usecases.WorkLogsInvalid{Results: results}
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.Save().ServeHTTP(recorder, httptest.NewRequest("POST", "/save-time?dry_run=1", strings.NewReader(`[{"issue_id": "MAT-1", "duration": 30}]`)))
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+
+	if decodeErrorEnvelope(t, recorder).Kind != usecases.ErrorPartialFailure {
+		t.Fatal("Unexpected error kind", recorder.Body.String())
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	handler := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatal("Unexpected status", recorder.Code)
+	}
+
+	if decodeErrorEnvelope(t, recorder).Kind != usecases.ErrorInternal {
+		t.Fatal("Unexpected error kind", recorder.Body.String())
+	}
+}
+
+func TestWorkTypesOfIssue(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{
+		workTypes: func(issueId string) ([]string, error) {
+			if issueId != "MAT-1" {
+				t.Fatal("Unexpected issue", issueId)
+			}
+
+			return []string{"Development", "Meeting"}, nil
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.WorkTypes().ServeHTTP(recorder, httptest.NewRequest("GET", "/worktypes?issue=MAT-1", nil))
+
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != `{"issue_id":"MAT-1","worktypes":["Development","Meeting"]}` {
+		t.Fatal("Unexpected response", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestWorkTypesOfIssueAcceptingAny(t *testing.T) {
+	web := NewWeb(&timeLoggerMock{
+		workTypes: func(issueId string) ([]string, error) {
+			return nil, nil
+		},
+	}, time.UTC)
+
+	recorder := httptest.NewRecorder()
+	web.WorkTypes().ServeHTTP(recorder, httptest.NewRequest("GET", "/worktypes?issue=JIRA-1", nil))
+
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != `{"issue_id":"JIRA-1","worktypes":[]}` {
+		t.Fatal("Unexpected response", recorder.Code, recorder.Body.String())
+	}
+}
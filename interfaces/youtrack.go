@@ -3,7 +3,7 @@ package interfaces
 import (
 	"bytes"
 	"crypto/tls"
-	"encoding/xml"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,6 +13,7 @@ import (
 	"regexp"
 
 	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
 )
 
 type YouTrackClient struct {
@@ -21,54 +22,309 @@ type YouTrackClient struct {
 	httpClient HttpClient
 }
 
-type workLogXml struct {
-	XMLName     xml.Name `xml:"workItem"`
-	Date        int64    `xml:"date"`
-	Duration    int      `xml:"duration"`
-	Description string   `xml:"description"`
-	Worktype    worktype `xml:"worktype"`
+const issueFields = "idReadable,summary,description,project(shortName,name),customFields(name,value(name,login,fullName,minutes))"
+
+const (
+	assigneeField   = "Assignee"
+	stateField      = "State"
+	estimationField = "Estimation"
+	spentTimeField  = "Spent time"
+)
+
+type issueJson struct {
+	IdReadable  string            `json:"idReadable"`
+	Summary     string            `json:"summary"`
+	Description string            `json:"description"`
+	Project     projectJson       `json:"project"`
+	Fields      []customFieldJson `json:"customFields"`
+}
+
+type projectJson struct {
+	ShortName string `json:"shortName"`
+	Name      string `json:"name"`
+}
+
+type customFieldJson struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// customFieldValueJson covers single value fields we care about:
+// enums and states have name, users have login and periods have minutes.
+type customFieldValueJson struct {
+	Name     string `json:"name"`
+	Login    string `json:"login"`
+	FullName string `json:"fullName"`
+	Minutes  int    `json:"minutes"`
+}
+
+type workItemJson struct {
+	Id       string            `json:"id,omitempty"`
+	Date     int64             `json:"date"`
+	Duration durationJson      `json:"duration"`
+	Text     string            `json:"text"`
+	Type     *workItemTypeJson `json:"type,omitempty"`
+}
+
+type durationJson struct {
+	Minutes int `json:"minutes"`
+}
+
+type workItemTypeJson struct {
+	Name string `json:"name"`
 }
-type worktype struct {
-	Name string `xml:"name"`
+
+func (i issueJson) field(name string) customFieldValueJson {
+	var value customFieldValueJson
+
+	for _, field := range i.Fields {
+		if field.Name == name {
+			// multi value fields are arrays and are left empty
+			json.Unmarshal(field.Value, &value)
+		}
+	}
+
+	return value
 }
 
-func (youtrackClient *YouTrackClient) SaveWorkLog(workLog domain.IssueWorkLog) error {
-	request, _ := youtrackClient.buildRequest(fmt.Sprintf("%s/rest/issue/%s/timetracking/workitem", youtrackClient.baseUrl, workLog.IssueId))
+func (i issueJson) toDomain() domain.Issue {
+	assignee := i.field(assigneeField)
+
+	if assignee.FullName == "" {
+		assignee.FullName = assignee.Login
+	}
+
+	return domain.Issue{
+		Id:          i.IdReadable,
+		Summary:     i.Summary,
+		Description: i.Description,
+		Project: domain.Project{
+			ShortName: i.Project.ShortName,
+			Name:      i.Project.Name,
+		},
+		Assignee:   assignee.FullName,
+		State:      i.field(stateField).Name,
+		Estimation: i.field(estimationField).Minutes,
+		SpentTime:  i.field(spentTimeField).Minutes,
+	}
+}
+
+func (youtrackClient *YouTrackClient) SaveWorkLog(workLog domain.IssueWorkLog) (string, error) {
+	request, _ := youtrackClient.buildRequest(youtrackClient.workItemUrl("", workLog))
 	request.Method = "POST"
-	request.Header.Add("Content-Type", "application/xml")
 
-	var workLogXml workLogXml
+	var created workItemJson
 
-	workLogXml.Date = workLog.Date.Unix() * 1000 // because milliseconds
-	workLogXml.Duration = workLog.Duration
-	workLogXml.Description = workLog.Description
-	workLogXml.Worktype = worktype{workLog.Type}
+	e := youtrackClient.sendWorkLog(&request, workLog, &created)
 
-	byteBody, e := xml.Marshal(workLogXml)
+	if e != nil {
+		return "", e
+	}
+
+	return created.Id, nil
+}
+
+func (youtrackClient *YouTrackClient) UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	request, _ := youtrackClient.buildRequest(youtrackClient.workItemUrl(workItemId, workLog))
+	request.Method = "POST"
+
+	var updated workItemJson
+
+	return youtrackClient.sendWorkLog(&request, workLog, &updated)
+}
+
+func (youtrackClient *YouTrackClient) sendWorkLog(request *http.Request, workLog domain.IssueWorkLog, result interface{}) error {
+	request.Header.Add("Content-Type", "application/json")
+
+	byteBody, e := json.Marshal(youtrackWorkItem(workLog))
+
+	if e != nil {
+		return e
+	}
 
 	request.Body = ioutil.NopCloser(bytes.NewReader(byteBody))
 	request.ContentLength = int64(len(byteBody))
-	response, e := youtrackClient.httpClient.Do(&request)
 
-	byteBody, e = ioutil.ReadAll(response.Body)
+	return youtrackClient.do(request, result)
+}
+
+// PreviewWorkLog returns request saving the log would send, the log is
+// checked against project settings by WorkTypes
+func (youtrackClient *YouTrackClient) PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+	body, err := json.Marshal(youtrackWorkItem(workLog))
+
+	if err != nil {
+		return usecases.WorkLogRequest{}, err
+	}
+
+	return usecases.WorkLogRequest{
+		Method: http.MethodPost,
+		Url:    youtrackClient.workItemUrl(workItemId, workLog),
+		Body:   body,
+	}, nil
+}
+
+type timeTrackingSettingsJson struct {
+	Enabled       bool               `json:"enabled"`
+	WorkItemTypes []workItemTypeJson `json:"workItemTypes"`
+}
+
+func (s timeTrackingSettingsJson) workItemTypeNames() []string {
+	var names []string
+
+	for _, workItemType := range s.WorkItemTypes {
+		names = append(names, workItemType.Name)
+	}
+
+	return names
+}
+
+// timeTrackingSettings reads settings of the project issue belongs to,
+// admin api knows projects only by their database id
+func (youtrackClient *YouTrackClient) timeTrackingSettings(issueId string) (timeTrackingSettingsJson, error) {
+	var issue struct {
+		Project struct {
+			Id string `json:"id"`
+		} `json:"project"`
+	}
+
+	request, _ := youtrackClient.buildRequest(fmt.Sprintf("%s/api/issues/%s?fields=project(id)", youtrackClient.baseUrl, issueId))
+
+	if err := youtrackClient.do(&request, &issue); err != nil {
+		return timeTrackingSettingsJson{}, err
+	}
+
+	var settings timeTrackingSettingsJson
+
+	request, _ = youtrackClient.buildRequest(fmt.Sprintf("%s/api/admin/projects/%s/timeTrackingSettings?fields=enabled,workItemTypes(name)", youtrackClient.baseUrl, issue.Project.Id))
+
+	err := youtrackClient.do(&request, &settings)
+
+	return settings, err
+}
+
+// WorkTypes lists work item types of the project issue belongs to, no
+// work can be logged when time tracking is disabled in it
+func (youtrackClient *YouTrackClient) WorkTypes(issueId string) ([]string, error) {
+	settings, err := youtrackClient.timeTrackingSettings(issueId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !settings.Enabled {
+		return nil, usecases.NewError(usecases.ErrorValidation, nil, "time tracking is disabled for %s", issueId)
+	}
+
+	return settings.workItemTypeNames(), nil
+}
+
+// workItemUrl is where work log is posted, a new work item is created
+// when work item id is empty
+func (youtrackClient *YouTrackClient) workItemUrl(workItemId string, workLog domain.IssueWorkLog) string {
+	if workItemId == "" {
+		return fmt.Sprintf("%s/api/issues/%s/timeTracking/workItems?fields=id", youtrackClient.baseUrl, workLog.IssueId)
+	}
+
+	return fmt.Sprintf("%s/api/issues/%s/timeTracking/workItems/%s?fields=id", youtrackClient.baseUrl, workLog.IssueId, workItemId)
+}
+
+func youtrackWorkItem(workLog domain.IssueWorkLog) workItemJson {
+	workItem := workItemJson{
+		Date:     workLog.Date.Unix() * 1000, // because milliseconds
+		Duration: durationJson{workLog.Duration},
+		Text:     workLog.Description,
+	}
+
+	if workLog.Type != "" {
+		workItem.Type = &workItemTypeJson{workLog.Type}
+	}
+
+	return workItem
+}
+
+type youtrackErrorJson struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// DeleteWorkLog removes work item, used to undo a log other destinations refused
+func (youtrackClient *YouTrackClient) DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	request, _ := youtrackClient.buildRequest(fmt.Sprintf("%s/api/issues/%s/timeTracking/workItems/%s", youtrackClient.baseUrl, workLog.IssueId, workItemId))
+	request.Method = "DELETE"
+
+	return youtrackClient.do(&request, nil)
+}
+
+// do sends request and decodes successful json response into result,
+// response is ignored when result is nil
+func (youtrackClient *YouTrackClient) do(request *http.Request, result interface{}) error {
+	response, e := youtrackClient.httpClient.Do(request)
 
 	if e != nil {
-		return e
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, e, "youtrack unreachable")
+	}
+
+	defer response.Body.Close()
+
+	byteBody, e := ioutil.ReadAll(response.Body)
+
+	if e != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, e, "cannot read youtrack response")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return youtrackError(request, response, byteBody)
 	}
 
-	if response.StatusCode != http.StatusCreated {
-		return errors.New("no new work item created")
+	if result == nil {
+		return nil
+	}
+
+	if e = json.Unmarshal(byteBody, result); e != nil {
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, e, "unexpected youtrack response")
 	}
 
 	return nil
 }
 
+// youtrackError turns unsuccessful response into typed error
+func youtrackError(request *http.Request, response *http.Response, body []byte) error {
+	var errorBody youtrackErrorJson
+
+	json.Unmarshal(body, &errorBody)
+
+	reason := errorBody.ErrorDescription
+
+	if reason == "" {
+		reason = errorBody.Error
+	}
+
+	if reason == "" {
+		reason = response.Status
+	}
+
+	cause := errors.New(reason)
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return usecases.NewError(usecases.ErrorNotFound, cause, "youtrack %s not found", request.URL.Path)
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return usecases.NewError(usecases.ErrorAuthFailed, cause, "youtrack refused credentials")
+	case response.StatusCode >= http.StatusInternalServerError:
+		return usecases.NewError(usecases.ErrorUpstreamUnavailable, cause, "youtrack failed")
+	default:
+		return usecases.NewError(usecases.ErrorValidation, cause, "youtrack rejected %s", request.URL.Path)
+	}
+}
+
 func (youtrackClient *YouTrackClient) buildRequest(requestUrl string) (http.Request, error) {
 	r := http.Request{}
 
 	r.Header = http.Header{}
 
 	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", youtrackClient.token))
+	r.Header.Add("Accept", "application/json")
 
 	u, err := url.Parse(requestUrl)
 
@@ -81,21 +337,45 @@ func (youtrackClient *YouTrackClient) buildRequest(requestUrl string) (http.Requ
 	return r, nil
 }
 
-func (youtrackClient *YouTrackClient) FindIssueByIssueId(issueId string) (domain.YouTrackIssue, error) {
+func (youtrackClient *YouTrackClient) FindIssueByIssueId(issueId string) (domain.Issue, error) {
+
+	request, _ := youtrackClient.buildRequest(fmt.Sprintf("%s/api/issues/%s?fields=%s", youtrackClient.baseUrl, issueId, issueFields))
+
+	var issue issueJson
 
-	request, _ := youtrackClient.buildRequest(fmt.Sprintf("%s/rest/issue/%s", youtrackClient.baseUrl, issueId))
+	e := youtrackClient.do(&request, &issue)
 
-	response, e := youtrackClient.httpClient.Do(&request)
+	if e != nil {
+		return domain.Issue{}, e
+	}
+
+	return issue.toDomain(), nil
+}
 
-	fmt.Println(e)
+// youtrackPageSize is how many entities admin api returns per request
+const youtrackPageSize = 100
 
-	b, _ := ioutil.ReadAll(response.Body)
+// ProjectShortNames lists short names of all projects user can see
+func (youtrackClient *YouTrackClient) ProjectShortNames() ([]string, error) {
+	var shortNames []string
 
-	var issue domain.YouTrackIssue
+	for skip := 0; ; skip += youtrackPageSize {
+		var projects []projectJson
 
-	e = xml.Unmarshal(b, &issue)
+		request, _ := youtrackClient.buildRequest(fmt.Sprintf("%s/api/admin/projects?fields=shortName&$skip=%d&$top=%d", youtrackClient.baseUrl, skip, youtrackPageSize))
 
-	return issue, nil
+		if err := youtrackClient.do(&request, &projects); err != nil {
+			return nil, err
+		}
+
+		for _, project := range projects {
+			shortNames = append(shortNames, project.ShortName)
+		}
+
+		if len(projects) < youtrackPageSize {
+			return shortNames, nil
+		}
+	}
 }
 
 func NewYouTrackClient(baseUrl, apikey string) *YouTrackClient {
@@ -1,7 +1,16 @@
 package interfaces
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/vizualni/meyougotrack/domain"
+	"github.com/vizualni/meyougotrack/usecases"
 )
 
 func TestExtractIssueIdWithOnlyUrl(t *testing.T) {
@@ -119,3 +128,238 @@ func validateExtractIssueId(input, output string, expectsError bool, t *testing.
 	}
 
 }
+
+func newTestYouTrackClient(server *httptest.Server) *YouTrackClient {
+	return &YouTrackClient{
+		baseUrl:    server.URL,
+		token:      "token",
+		httpClient: &OfficialHttpClientAdapter{server.Client()},
+	}
+}
+
+func TestFindIssueByIssueId(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/issues/MAT-123" {
+			t.Error("Unexpected path", r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("fields") == "" {
+			t.Error("Fields should be requested")
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Error("Missing authorization")
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{
+			"idReadable": "MAT-123",
+			"summary": "Fix login",
+			"project": {"shortName": "MAT", "name": "Matrix"},
+			"customFields": [
+				{"name": "Assignee", "value": {"login": "jdoe", "fullName": "John Doe"}},
+				{"name": "State", "value": {"name": "In Progress"}},
+				{"name": "Estimation", "value": {"minutes": 240}},
+				{"name": "Spent time", "value": {"minutes": 90}},
+				{"name": "Tags", "value": [{"name": "one"}]},
+				{"name": "Due Date", "value": null}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	issue, err := newTestYouTrackClient(server).FindIssueByIssueId("MAT-123")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := domain.Issue{
+		Id:         "MAT-123",
+		Summary:    "Fix login",
+		Project:    domain.Project{ShortName: "MAT", Name: "Matrix"},
+		Assignee:   "John Doe",
+		State:      "In Progress",
+		Estimation: 240,
+		SpentTime:  90,
+	}
+
+	if issue != expected {
+		t.Fatalf("Unexpected issue %+v", issue)
+	}
+}
+
+func TestFindIssueByIssueIdNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Not Found"}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestYouTrackClient(server).FindIssueByIssueId("MAT-123")
+
+	if usecases.KindOf(err) != usecases.ErrorNotFound {
+		t.Fatal("Expected not found error", err)
+	}
+}
+
+func TestFindIssueByIssueIdWithBadToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Unauthorized", "error_description": "Invalid token"}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestYouTrackClient(server).FindIssueByIssueId("MAT-123")
+
+	if usecases.KindOf(err) != usecases.ErrorAuthFailed {
+		t.Fatal("Expected auth failed error", err)
+	}
+}
+
+func TestSaveWorkLogPostsJsonWorkItem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/issues/MAT-123/timeTracking/workItems" {
+			t.Error("Unexpected request", r.Method, r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var workItem workItemJson
+		json.Unmarshal(body, &workItem)
+
+		if workItem.Duration.Minutes != 45 || workItem.Text != "lalalla" || workItem.Type.Name != "Work" {
+			t.Errorf("Unexpected work item %s", body)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		if workItem.Date != 1514768461000 {
+			t.Error("Date should be in milliseconds", workItem.Date)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(`{"id": "115-4", "$type": "IssueWorkItem"}`))
+	}))
+	defer server.Close()
+
+	workItemId, err := newTestYouTrackClient(server).SaveWorkLog(domain.IssueWorkLog{
+		IssueId:     "MAT-123",
+		Description: "lalalla",
+		Type:        "Work",
+		Duration:    45,
+		Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if workItemId != "115-4" {
+		t.Fatal("Unexpected work item id", workItemId)
+	}
+}
+
+func TestUpdateWorkLogPostsToExistingWorkItem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/issues/MAT-123/timeTracking/workItems/115-4" {
+			t.Error("Unexpected path", r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"id": "115-4"}`))
+	}))
+	defer server.Close()
+
+	err := newTestYouTrackClient(server).UpdateWorkLog("115-4", domain.IssueWorkLog{
+		IssueId:  "MAT-123",
+		Duration: 45,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteWorkLogIgnoresEmptyResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/issues/MAT-123/timeTracking/workItems/115-4" {
+			t.Error("Unexpected request", r.Method, r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+	}))
+	defer server.Close()
+
+	err := newTestYouTrackClient(server).DeleteWorkLog("115-4", domain.IssueWorkLog{IssueId: "MAT-123"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPreviewWorkLogOnlyBuildsRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Preview should not send anything", r.Method, r.URL.Path)
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := newTestYouTrackClient(server)
+	workLog := domain.IssueWorkLog{IssueId: "MAT-123", Type: "Meeting", Duration: 45, Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	request, err := client.PreviewWorkLog("115-4", workLog)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.Method != http.MethodPost || request.Url != server.URL+"/api/issues/MAT-123/timeTracking/workItems/115-4?fields=id" {
+		t.Fatal("Unexpected request", request.Method, request.Url)
+	}
+
+	var workItem workItemJson
+	json.Unmarshal(request.Body, &workItem)
+
+	if workItem.Duration.Minutes != 45 || workItem.Type.Name != "Meeting" || workItem.Date != 1514764800000 {
+		t.Fatalf("Unexpected body %s", request.Body)
+	}
+}
+
+func TestWorkTypesOfProjectWithoutTimeTracking(t *testing.T) {
+	enabled := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/issues/MAT-123":
+			w.Write([]byte(`{"project": {"id": "0-7"}}`))
+		case "/api/admin/projects/0-7/timeTrackingSettings":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"enabled":       enabled,
+				"workItemTypes": []map[string]string{{"name": "Development"}, {"name": "Meeting"}},
+			})
+		default:
+			t.Error("Unexpected path", r.URL.Path)
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+	}))
+	defer server.Close()
+
+	client := newTestYouTrackClient(server)
+
+	if workTypes, err := client.WorkTypes("MAT-123"); err != nil || strings.Join(workTypes, ", ") != "Development, Meeting" {
+		t.Fatal("Unexpected work types", workTypes, err)
+	}
+
+	enabled = false
+
+	if _, err := client.WorkTypes("MAT-123"); usecases.KindOf(err) != usecases.ErrorValidation {
+		t.Fatal("Expected time tracking to be disabled", err)
+	}
+}
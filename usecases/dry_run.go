@@ -0,0 +1,87 @@
+package usecases
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+const (
+	StatusWouldSave   SaveStatus = "would_save"
+	StatusWouldUpdate SaveStatus = "would_update"
+	// issue repository wouldn't accept the log, e.g. unknown work type
+	StatusInvalid SaveStatus = "invalid"
+)
+
+// WorkLogRequest is what issue repository would be sent for a work log
+type WorkLogRequest struct {
+	Method string          `json:"method"`
+	Url    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// WorkLogsInvalid is returned when issue repository wouldn't accept at
+// least one work log, e.g. because of its work type.
+type WorkLogsInvalid struct {
+	Results []SaveResult
+}
+
+func (e WorkLogsInvalid) Error() string {
+	var invalid []string
+
+	for _, result := range e.Results {
+		if result.Status == StatusInvalid {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", result.IssueId, result.Reason))
+		}
+	}
+
+	return fmt.Sprintf("work logs invalid: %s", strings.Join(invalid, ", "))
+}
+
+// PreviewWorklogs is a dry run of SaveWorklogs. Logs are checked against
+// their issues and results hold requests that would be sent, but nothing is
+// written to issue repository, destinations or ledger.
+func (t *TimeLoggerInteractor) PreviewWorklogs(logs []SaveTimeLog) ([]SaveResult, error) {
+	return t.saveWorklogs(logs, true)
+}
+
+// previewWorkLog tells what syncWorkLog would do with the log
+func (t *TimeLoggerInteractor) previewWorkLog(log domain.IssueWorkLog) (SaveStatus, string, *WorkLogRequest, error) {
+	var entry *domain.WorkLogLedgerEntry
+	var err error
+
+	if t.WorkLogLedger != nil && log.EntryId != "" {
+		entry, err = t.WorkLogLedger.Find(log.EntryId, log.Date, log.IssueId)
+
+		if err != nil {
+			return StatusRejected, "", nil, err
+		}
+	}
+
+	status := StatusWouldSave
+	workItemId := ""
+
+	switch {
+	case entry == nil || !entry.Synced():
+	case entry.Matches(log):
+		return StatusAlreadySynced, entry.WorkItemId, nil, nil
+	default:
+		status = StatusWouldUpdate
+		workItemId = entry.WorkItemId
+	}
+
+	request, err := t.IssueRepository.PreviewWorkLog(workItemId, log)
+
+	if err != nil {
+		switch KindOf(err) {
+		case ErrorValidation, ErrorNotFound:
+			return StatusInvalid, workItemId, nil, err
+		default:
+			return StatusRejected, workItemId, nil, err
+		}
+	}
+
+	return status, workItemId, &request, nil
+}
@@ -0,0 +1,61 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+)
+
+type ErrorKind string
+
+const (
+	ErrorNotFound            ErrorKind = "not_found"
+	ErrorUpstreamUnavailable ErrorKind = "upstream_unavailable"
+	ErrorAuthFailed          ErrorKind = "auth_failed"
+	ErrorValidation          ErrorKind = "validation"
+	ErrorPartialFailure      ErrorKind = "partial_failure"
+	ErrorInternal            ErrorKind = "internal"
+)
+
+// Error is an error with a kind, so that callers can react to it
+// (e.g. web layer picks status code) without knowing where it came from.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewError(kind ErrorKind, err error, format string, args ...interface{}) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+// KindOf returns kind of the error, or ErrorInternal for untyped errors.
+func KindOf(err error) ErrorKind {
+	switch err.(type) {
+	case NoIssueIdFound, WorkLogsRejected, WorkLogsInvalid:
+		return ErrorPartialFailure
+	}
+
+	var e *Error
+
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return ErrorInternal
+}
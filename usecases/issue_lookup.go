@@ -0,0 +1,76 @@
+package usecases
+
+import (
+	"sync"
+
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+// findIssues looks up every distinct non empty issue id once, using at most
+// IssueLookupWorkers concurrent requests. Issues that do not exist are
+// left out of the result, any other error fails the whole lookup.
+func (t *TimeLoggerInteractor) findIssues(issueIds []string) (map[string]*domain.Issue, error) {
+	var unique []string
+	seen := map[string]bool{}
+
+	for _, issueId := range issueIds {
+		if issueId != "" && !seen[issueId] {
+			seen[issueId] = true
+			unique = append(unique, issueId)
+		}
+	}
+
+	issues := make([]*domain.Issue, len(unique))
+	errs := make([]error, len(unique))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := t.IssueLookupWorkers
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				issue, err := t.IssueRepository.FindIssueByIssueId(unique[index])
+
+				switch {
+				case err == nil:
+					issues[index] = &issue
+				case KindOf(err) == ErrorNotFound:
+					// entry points to an issue that does not exist, same as no id
+				default:
+					errs[index] = err
+				}
+			}
+		}()
+	}
+
+	for index := range unique {
+		jobs <- index
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	found := map[string]*domain.Issue{}
+
+	for index, issueId := range unique {
+		if errs[index] != nil {
+			return nil, errs[index]
+		}
+
+		if issues[index] != nil {
+			found[issueId] = issues[index]
+		}
+	}
+
+	return found, nil
+}
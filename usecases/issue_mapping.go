@@ -0,0 +1,68 @@
+package usecases
+
+import "github.com/vizualni/meyougotrack/domain"
+
+// IssueMapping is what a mapping rule says about an entry
+type IssueMapping struct {
+	Rule        string `json:"rule"`
+	IssueId     string `json:"issue_id"`
+	WorkType    string `json:"worktype,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// IssueMapper links entries which don't mention any issue, e.g. standups,
+// to an issue
+type IssueMapper interface {
+	Map(entry domain.TimeEntry) (IssueMapping, bool)
+}
+
+// DefaultIssueSource is a time source whose entries are logged to default
+// issue when neither their title nor any mapping rule links them, e.g. ics
+type DefaultIssueSource interface {
+	DefaultIssueId() string
+}
+
+// mapIssue applies mapping to the entry and returns its issue id,
+// empty when nothing matched
+func (t *TimeLoggerInteractor) mapIssue(entry *domain.TimeEntry) string {
+	if t.IssueMapper == nil {
+		return ""
+	}
+
+	mapping, ok := t.IssueMapper.Map(*entry)
+
+	if !ok {
+		return ""
+	}
+
+	entry.IssueId = mapping.IssueId
+	entry.MappedBy = mapping.Rule
+
+	if mapping.WorkType != "" {
+		entry.WorkType = mapping.WorkType
+	}
+
+	if mapping.Description != "" {
+		entry.Description = mapping.Description
+	}
+
+	return mapping.IssueId
+}
+
+// defaultIssue links the entry to default issue of its source, if it has
+// one, and returns its issue id
+func (t *TimeLoggerInteractor) defaultIssue(entry *domain.TimeEntry) string {
+	for _, source := range t.TimeSources {
+		if source.Name() != entry.Source {
+			continue
+		}
+
+		if defaults, ok := source.(DefaultIssueSource); ok {
+			entry.IssueId = defaults.DefaultIssueId()
+		}
+
+		break
+	}
+
+	return entry.IssueId
+}
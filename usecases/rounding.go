@@ -0,0 +1,145 @@
+package usecases
+
+import (
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+// DurationRounding rounds durations of loggable items, e.g. 7 minutes
+// of trello list dwell up to 15
+type DurationRounding struct {
+	Default domain.RoundingPolicy
+	// Projects override default policy, by project key
+	Projects map[string]domain.RoundingPolicy
+	// DailyCap is the most minutes logged per day to issues, zero for no cap.
+	// Items not linked to any issue are not logged, so they don't count.
+	DailyCap int64
+}
+
+func (r *DurationRounding) policyOf(item LoggableItem) domain.RoundingPolicy {
+	if item.Issue != nil {
+		if policy, ok := r.Projects[domain.ProjectKey(item.Issue.Project.ShortName)]; ok {
+			return policy
+		}
+	}
+
+	return r.Default
+}
+
+// roundDurations rounds every item by policy of its project and then cuts
+// days over the daily cap, starting from the last linked item of the day.
+// Parts of a split entry are rounded together. Raw durations are kept in
+// entry rounding.
+func (t *TimeLoggerInteractor) roundDurations(items []LoggableItem) {
+	if t.Rounding == nil {
+		return
+	}
+
+	days := map[string][]*domain.TimeEntry{}
+	splits := map[string][]LoggableItem{}
+
+	for _, item := range items {
+		entry := item.Entry
+		day := entry.Date.Format(domain.LedgerDateFormat)
+
+		if item.Issue != nil {
+			days[day] = append(days[day], entry)
+		}
+
+		if entry.Split != nil {
+			key := entry.Id + "|" + day
+			splits[key] = append(splits[key], item)
+			continue
+		}
+
+		policy := t.Rounding.policyOf(item)
+
+		entry.Rounding = &domain.EntryRounding{
+			Raw:    entry.Duration,
+			Policy: policy.String(),
+		}
+		entry.Duration = policy.Round(entry.Duration)
+	}
+
+	for _, parts := range splits {
+		t.Rounding.roundSplit(parts)
+	}
+
+	if t.Rounding.DailyCap <= 0 {
+		return
+	}
+
+	for _, entries := range days {
+		var total int64
+
+		for _, entry := range entries {
+			total += entry.Duration
+		}
+
+		excess := total - t.Rounding.DailyCap
+
+		for index := len(entries) - 1; index >= 0 && excess > 0; index-- {
+			entry := entries[index]
+
+			if entry.Duration <= 0 {
+				continue
+			}
+
+			cut := entry.Duration
+
+			if cut > excess {
+				cut = excess
+			}
+
+			entry.Duration -= cut
+			entry.Rounding.Capped = true
+			excess -= cut
+		}
+	}
+}
+
+// roundSplit rounds the whole entry by policy of its first part and shares
+// the rounded total among parts in increments of the policy, so that parts
+// add up to what the entry alone would be rounded to
+func (r *DurationRounding) roundSplit(parts []LoggableItem) {
+	policy := r.policyOf(parts[0])
+
+	var raw int64
+
+	for _, part := range parts {
+		raw += part.Entry.Duration
+	}
+
+	total := policy.Round(raw)
+	unit := int64(1)
+
+	if policy.Mode != "" && policy.Mode != domain.RoundNone && policy.Increment > 0 {
+		unit = policy.Increment
+	}
+
+	units := total / unit
+	var given int64
+
+	for _, part := range parts {
+		entry := part.Entry
+
+		entry.Rounding = &domain.EntryRounding{
+			Raw:    entry.Duration,
+			Policy: policy.String(),
+		}
+
+		if raw > 0 {
+			entry.Duration = entry.Duration * units / raw * unit
+		}
+
+		given += entry.Duration
+	}
+
+	// increments lost to rounding down go to first parts, minutes left
+	// over by minimum go to the first one
+	for i := 0; given+unit <= total; i++ {
+		parts[i%len(parts)].Entry.Duration += unit
+		given += unit
+	}
+
+	parts[0].Entry.Duration += total - given
+}
@@ -0,0 +1,173 @@
+package usecases
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+// WorkLogSink is an extra destination every synced work log is copied to,
+// e.g. toggl for billing. Issue is given so that sinks can map its project.
+type WorkLogSink interface {
+	Name() string
+	SaveWorkLog(workLog domain.IssueWorkLog, issue domain.Issue) (string, error)
+	UpdateWorkLog(itemId string, workLog domain.IssueWorkLog, issue domain.Issue) error
+	DeleteWorkLog(itemId string, workLog domain.IssueWorkLog) error
+}
+
+// Destination is a sink together with how much its failures matter.
+// When a required destination refuses a new log, everything created for
+// that log is deleted again. Optional destinations are best effort.
+type Destination struct {
+	Sink     WorkLogSink
+	Required bool
+}
+
+// DestinationResult describes what happened with a log in one of the sinks
+type DestinationResult struct {
+	Destination string     `json:"destination"`
+	Required    bool       `json:"required"`
+	Status      SaveStatus `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	ItemId      string     `json:"item_id,omitempty"`
+}
+
+// syncSinks copies work log to every sink. Sinks which already have the log
+// (by item ids remembered in ledger) get it only if it changed. Returned
+// item ids include the new ones and the bool tells if any was written.
+func (t *TimeLoggerInteractor) syncSinks(log domain.IssueWorkLog, status SaveStatus, itemIds map[string]string) ([]DestinationResult, map[string]string, bool) {
+	if len(t.Destinations) == 0 {
+		return nil, itemIds, false
+	}
+
+	synced := map[string]string{}
+
+	for name, itemId := range itemIds {
+		synced[name] = itemId
+	}
+
+	var issue *domain.Issue
+	var issueErr error
+
+	results := make([]DestinationResult, 0, len(t.Destinations))
+	written := false
+
+	for _, destination := range t.Destinations {
+		sink := destination.Sink
+
+		result := DestinationResult{
+			Destination: sink.Name(),
+			Required:    destination.Required,
+			ItemId:      synced[sink.Name()],
+		}
+
+		if result.ItemId != "" && status != StatusUpdated {
+			result.Status = StatusAlreadySynced
+			results = append(results, result)
+			continue
+		}
+
+		// issue is fetched only once and only if some sink needs it
+		if issue == nil && issueErr == nil {
+			found, err := t.IssueRepository.FindIssueByIssueId(log.IssueId)
+			issue, issueErr = &found, err
+		}
+
+		var err error
+
+		switch {
+		case issueErr != nil:
+			err = issueErr
+		case result.ItemId == "":
+			result.Status = StatusSaved
+			result.ItemId, err = sink.SaveWorkLog(log, *issue)
+		default:
+			result.Status = StatusUpdated
+			err = sink.UpdateWorkLog(result.ItemId, log, *issue)
+		}
+
+		if err != nil {
+			result.Status = StatusRejected
+			result.Reason = err.Error()
+		} else {
+			synced[sink.Name()] = result.ItemId
+			written = true
+		}
+
+		results = append(results, result)
+	}
+
+	return results, synced, written
+}
+
+// requiredRejected lists required destinations that refused the log
+func requiredRejected(results []DestinationResult) []string {
+	var rejected []string
+
+	for _, result := range results {
+		if result.Required && result.Status == StatusRejected {
+			rejected = append(rejected, result.Destination)
+		}
+	}
+
+	return rejected
+}
+
+// updateRejected tells if any destination refused to update its copy
+func updateRejected(results []DestinationResult) bool {
+	for _, result := range results {
+		if result.Status == StatusRejected && result.ItemId != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compensate deletes everything created for the log in this sync, the work
+// item in issue repository included. Updated and already synced items are
+// left as they are since their previous state is not known, unless the work
+// item is new, then they are copies left by an earlier compensation. Returns
+// status of the work item, item ids still existing in sinks and the reason.
+func (t *TimeLoggerInteractor) compensate(log domain.IssueWorkLog, status SaveStatus, workItemId string, results []DestinationResult, itemIds map[string]string, rejected []string) (SaveStatus, map[string]string, error) {
+	var failures []string
+
+	for index := range results {
+		switch {
+		case results[index].Status == StatusSaved:
+		case status == StatusSaved && (results[index].Status == StatusAlreadySynced || results[index].Status == StatusUpdated):
+		default:
+			continue
+		}
+
+		err := t.Destinations[index].Sink.DeleteWorkLog(results[index].ItemId, log)
+
+		if err != nil {
+			results[index].Status = StatusCompensationFailed
+			results[index].Reason = err.Error()
+			failures = append(failures, results[index].Destination)
+			continue
+		}
+
+		results[index].Status = StatusCompensated
+		delete(itemIds, results[index].Destination)
+	}
+
+	if status == StatusSaved {
+		if err := t.IssueRepository.DeleteWorkLog(workItemId, log); err != nil {
+			status = StatusCompensationFailed
+			failures = append(failures, fmt.Sprintf("work item %s: %s", workItemId, err))
+		} else {
+			status = StatusCompensated
+		}
+	}
+
+	reason := fmt.Sprintf("required destination %s rejected the log", strings.Join(rejected, ", "))
+
+	if len(failures) > 0 {
+		reason += fmt.Sprintf(", could not undo %s", strings.Join(failures, ", "))
+	}
+
+	return status, itemIds, NewError(ErrorPartialFailure, nil, reason)
+}
@@ -0,0 +1,54 @@
+package usecases
+
+import "github.com/vizualni/meyougotrack/domain"
+
+// IssueIdCandidatesExtractor is an extractor that finds every issue id in
+// the input, not only the first one
+type IssueIdCandidatesExtractor interface {
+	ExtractAll(input string) []IssueIdCandidate
+}
+
+// splitEntries splits entries mentioning several issues in their title, or
+// annotating them in description, into one entry per issue. Description can
+// annotate only issue ids extractor finds in it. Entries whose issue was
+// given by the source are left as they are.
+func (t *TimeLoggerInteractor) splitEntries(entries []domain.TimeEntry) []domain.TimeEntry {
+	var split []domain.TimeEntry
+
+	for _, entry := range entries {
+		if entry.IssueId != "" {
+			split = append(split, entry)
+			continue
+		}
+
+		split = append(split, domain.SplitEntry(entry, t.issueIdsOf(entry.Title), t.issueIdsOf(entry.Description))...)
+	}
+
+	return split
+}
+
+// issueIdsOf returns distinct issue ids in order of appearance, only the
+// first one is known when extractor can't find them all
+func (t *TimeLoggerInteractor) issueIdsOf(input string) []string {
+	extractor, ok := t.IssueIdExtractor.(IssueIdCandidatesExtractor)
+
+	if !ok {
+		if issueId, err := t.IssueIdExtractor.Extract(input); err == nil {
+			return []string{issueId}
+		}
+
+		return nil
+	}
+
+	var issueIds []string
+	seen := map[string]bool{}
+
+	for _, candidate := range extractor.ExtractAll(input) {
+		if !seen[candidate.IssueId] {
+			seen[candidate.IssueId] = true
+			issueIds = append(issueIds, candidate.IssueId)
+		}
+	}
+
+	return issueIds
+}
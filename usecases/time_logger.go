@@ -1,36 +1,76 @@
 package usecases
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/vizualni/meyougotrack/domain"
 )
 
-type TrelloYouTrackLink struct {
-	Card  *domain.TrelloCardDoingDuration `json:"card"`
-	Issue *domain.YouTrackIssue           `json:"issue"`
+// LoggableItem is a time entry together with issue it belongs to
+type LoggableItem struct {
+	Entry  *domain.TimeEntry          `json:"entry"`
+	Issue  *domain.Issue              `json:"issue"`
+	Ledger *domain.WorkLogLedgerEntry `json:"ledger"`
 }
 
-type TrelloRepository interface {
-	GetAllCards(boardId string, doingListName string) []domain.TrelloCardDoingDuration
+// TimeSource is anything that knows where the time went, e.g. trello.
+type TimeSource interface {
+	Name() string
+	GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error)
 }
 
-type YouTrackRepository interface {
-	FindIssueByIssueId(issueId string) (domain.YouTrackIssue, error)
-	SaveWorkLog(workLog domain.IssueWorkLog) error
+// IssueRepository is where issues live and work logs are written to,
+// youtrack or jira.
+type IssueRepository interface {
+	FindIssueByIssueId(issueId string) (domain.Issue, error)
+	SaveWorkLog(workLog domain.IssueWorkLog) (string, error)
+	UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error
+	DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error
+	// PreviewWorkLog checks work log against its issue and returns request
+	// saving it (or updating when work item id is given) would send
+	PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (WorkLogRequest, error)
+}
+
+// WorkLogLedger keeps track of work items already sent to issue repository.
+// Find returns nil when nothing was synced for given entry, date and issue.
+type WorkLogLedger interface {
+	Find(entryId string, date time.Time, issueId string) (*domain.WorkLogLedgerEntry, error)
+	// FindAll finds entries by ledger keys at once, missing ones are left out
+	FindAll(keys []string) (map[string]*domain.WorkLogLedgerEntry, error)
+	Save(entry domain.WorkLogLedgerEntry) error
 }
 
 type IssueIdExtractor interface {
 	Extract(input string) (string, error)
 }
 
+// IssueIdCandidate is an issue id found in text between Start and End bytes
+type IssueIdCandidate struct {
+	IssueId string `json:"issue_id"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+}
+
 type TimeLoggerInteractor struct {
-	TrelloRepository   TrelloRepository
-	IssueIdExtractor   IssueIdExtractor
-	YouTrackRepository YouTrackRepository
+	TimeSources      []TimeSource
+	IssueIdExtractor IssueIdExtractor
+	IssueRepository  IssueRepository
+	WorkLogLedger    WorkLogLedger
+	// Destinations get a copy of every log written to issue repository
+	Destinations []Destination
+	// IssueLookupWorkers limits concurrent issue lookups, defaults to 1
+	IssueLookupWorkers int
+	// IssueMapper links entries without issue id in their title, optional
+	IssueMapper IssueMapper
+	// Rounding rounds durations of loggable items, they are left as they are when nil
+	Rounding *DurationRounding
 }
 
 type SaveTimeLog struct {
+	EntryId     string
+	IssueId     string // extracted from title when empty
 	Title       string
 	Duration    int
 	Date        time.Time
@@ -39,41 +79,136 @@ type SaveTimeLog struct {
 }
 
 type TimeLogger interface {
-	GetTrelloYouTrackCards(boardId string, listDoingName string) []TrelloYouTrackLink
-	SaveWorklogs(logs []SaveTimeLog) error
+	GetLoggableItems(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]LoggableItem, error)
+	SaveWorklogs(logs []SaveTimeLog) ([]SaveResult, error)
+	PreviewWorklogs(logs []SaveTimeLog) ([]SaveResult, error)
+	WorkTypes(issueId string) ([]string, error)
 }
 
-func (t *TimeLoggerInteractor) GetTrelloYouTrackCards(boardId string, listDoingName string) []TrelloYouTrackLink {
-	cards := t.TrelloRepository.GetAllCards(boardId, listDoingName)
+// GetLoggableItems collects entries from all time sources and links them
+// to issues found in their titles. Entries mentioning several issues are
+// split into one item per issue, durations are rounded last.
+func (t *TimeLoggerInteractor) GetLoggableItems(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]LoggableItem, error) {
+	var entries []domain.TimeEntry
 
-	var linked []TrelloYouTrackLink
+	for _, source := range t.TimeSources {
+		sourceEntries, err := source.GetTimeEntries(filter, window)
 
-	for index := range cards {
-		cardTitle := cards[index].Title
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, sourceEntries...)
+	}
 
-		// doesnt really matter if we cannot find exact id from trello title
-		youtrackIssueId, err := t.IssueIdExtractor.Extract(cardTitle)
+	entries = t.splitEntries(entries)
 
-		var youtrackIssue *domain.YouTrackIssue = nil
+	issueIds := make([]string, len(entries))
+
+	for index := range entries {
+		if entries[index].IssueId != "" {
+			issueIds[index] = entries[index].IssueId
+			continue
+		}
+
+		// doesnt really matter if we cannot find exact id from the title
+		issueId, err := t.IssueIdExtractor.Extract(entries[index].Title)
 
 		if err == nil {
-			yt, err := t.YouTrackRepository.FindIssueByIssueId(youtrackIssueId)
-			if err != nil {
-				panic("issue with that id not found")
-			}
-			youtrackIssue = &yt
+			issueIds[index] = issueId
+			continue
+		}
+
+		issueIds[index] = t.mapIssue(&entries[index])
+
+		if issueIds[index] == "" {
+			issueIds[index] = t.defaultIssue(&entries[index])
 		}
+	}
+
+	issues, err := t.findIssues(issueIds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var items []LoggableItem
+	ledgerKeys := make([]string, len(entries))
 
-		link := TrelloYouTrackLink{
-			Card:  &cards[index],
-			Issue: youtrackIssue,
+	for index := range entries {
+		item := LoggableItem{
+			Entry: &entries[index],
+		}
+
+		if issue, ok := issues[issueIds[index]]; ok {
+			item.Issue = issue
+
+			if entries[index].Id != "" {
+				ledgerKeys[index] = domain.LedgerKey(entries[index].Id, entries[index].Date, issueIds[index])
+			}
 		}
 
-		linked = append(linked, link)
+		items = append(items, item)
+	}
+
+	if err := t.findLedgerEntries(items, ledgerKeys); err != nil {
+		return nil, err
+	}
+
+	t.roundDurations(items)
+
+	return items, nil
+
+}
+
+type SaveStatus string
+
+const (
+	StatusSaved               SaveStatus = "saved"
+	StatusUpdated             SaveStatus = "updated"
+	StatusAlreadySynced       SaveStatus = "already_synced"
+	StatusSkippedZeroDuration SaveStatus = "skipped_zero_duration"
+	StatusNoIssueId           SaveStatus = "no_issue_id"
+	StatusRejected            SaveStatus = "rejected"
+	// work item was created but deleted again since a required destination failed
+	StatusCompensated SaveStatus = "compensated"
+	// work item or a copy should have been deleted again but is still there
+	StatusCompensationFailed SaveStatus = "compensation_failed"
+)
+
+// SaveResult describes what happened with a single log, in the same order
+// as the logs were given to SaveWorklogs.
+type SaveResult struct {
+	EntryId    string     `json:"entry_id"`
+	Title      string     `json:"title"`
+	IssueId    string     `json:"issue_id"`
+	Status     SaveStatus `json:"status"`
+	Reason     string     `json:"reason,omitempty"`
+	WorkItemId string     `json:"work_item_id,omitempty"`
+	// Destinations are results of copying the log to work log sinks
+	Destinations []DestinationResult `json:"destinations,omitempty"`
+	// Request would be sent to issue repository, only set by dry run
+	Request *WorkLogRequest `json:"request,omitempty"`
+}
+
+func (r SaveResult) Failed() bool {
+	return r.Status == StatusNoIssueId || r.Status == StatusInvalid || r.Rejected()
+}
+
+// Rejected tells if issue repository or any required destination refused
+// the log. Failures of optional destinations are only reported.
+func (r SaveResult) Rejected() bool {
+	if r.Status == StatusRejected || r.Status == StatusCompensated || r.Status == StatusCompensationFailed {
+		return true
 	}
 
-	return linked
+	for _, destination := range r.Destinations {
+		if destination.Required && destination.Status == StatusRejected {
+			return true
+		}
+	}
 
+	return false
 }
 
 type NoIssueIdFound struct {
@@ -84,26 +219,78 @@ func (NoIssueIdFound) Error() string {
 	return "no issue id found"
 }
 
-func (t *TimeLoggerInteractor) SaveWorklogs(logs []SaveTimeLog) error {
+// WorkLogsRejected is returned when issue repository refused at least one work log.
+type WorkLogsRejected struct {
+	Results []SaveResult
+}
+
+func (e WorkLogsRejected) Error() string {
+	var rejected []string
+
+	for _, result := range e.Results {
+		if result.Status == StatusRejected || result.Status == StatusCompensated || result.Status == StatusCompensationFailed {
+			rejected = append(rejected, fmt.Sprintf("%s: %s", result.IssueId, result.Reason))
+		}
+
+		for _, destination := range result.Destinations {
+			if destination.Required && destination.Status == StatusRejected {
+				rejected = append(rejected, fmt.Sprintf("%s (%s): %s", result.IssueId, destination.Destination, destination.Reason))
+			}
+		}
+	}
+
+	return fmt.Sprintf("work logs rejected: %s", strings.Join(rejected, ", "))
+}
+
+// SaveWorklogs tries to save every log, even when some of them fail.
+// Returned error is WorkLogsRejected if anything was refused, otherwise
+// WorkLogsInvalid if some logs had work type their issue doesn't accept,
+// otherwise NoIssueIdFound if some logs had no issue id.
+func (t *TimeLoggerInteractor) SaveWorklogs(logs []SaveTimeLog) ([]SaveResult, error) {
+	return t.saveWorklogs(logs, false)
+}
+
+// saveWorklogs only previews logs when dryRun is set, in which case
+// WorkLogsInvalid is returned for logs issue repository wouldn't accept
+func (t *TimeLoggerInteractor) saveWorklogs(logs []SaveTimeLog, dryRun bool) ([]SaveResult, error) {
 
 	// error storage for issues with no id
 	noIssueIdsFound := NoIssueIdFound{
 		logs: []SaveTimeLog{},
 	}
 
-	var issueWorkLogs []domain.IssueWorkLog
+	results := make([]SaveResult, 0, len(logs))
+	rejected := false
+	invalid := false
 
-	// extract issue id and save it to an array
-	// otherwise add error to array
 	for _, log := range logs {
-		issueId, err := t.IssueIdExtractor.Extract(log.Title)
+		result := SaveResult{
+			EntryId: log.EntryId,
+			Title:   log.Title,
+		}
+
+		issueId, err := t.issueIdOf(log)
 
 		if err != nil {
 			noIssueIdsFound.logs = append(noIssueIdsFound.logs, log)
+			result.Status = StatusNoIssueId
+			result.Reason = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.IssueId = issueId
+
+		// skip log with zero duration
+		if log.Duration <= 0 {
+			result.Status = StatusSkippedZeroDuration
+			results = append(results, result)
 			continue
 		}
 
 		workLog := domain.IssueWorkLog{
+			EntryId:     log.EntryId,
+			Title:       log.Title,
 			Date:        log.Date,
 			Duration:    log.Duration,
 			Type:        log.WorkType,
@@ -111,26 +298,200 @@ func (t *TimeLoggerInteractor) SaveWorklogs(logs []SaveTimeLog) error {
 			IssueId:     issueId,
 		}
 
-		issueWorkLogs = append(issueWorkLogs, workLog)
-	}
+		// unknown work type would be refused by issue repository anyway
+		if err = t.checkWorkType(workLog); err != nil {
+			result.Status = StatusRejected
 
-	// todo: testovi
-	// if len(noIssueIdsFound.logs) > 0 {
-	// 	return noIssueIdsFound
-	// }
+			if KindOf(err) == ErrorValidation {
+				result.Status = StatusInvalid
+			}
+		} else if dryRun {
+			result.Status, result.WorkItemId, result.Request, err = t.previewWorkLog(workLog)
+		} else {
+			result.Status, result.WorkItemId, result.Destinations, err = t.syncWorkLog(workLog)
+		}
+
+		if err != nil {
+			result.Reason = err.Error()
+		}
 
-	// saving logs to repository
-	for _, log := range issueWorkLogs {
+		if result.Rejected() {
+			rejected = true
+		}
 
-		// skip log with zero duration
-		if log.Duration <= 0 {
-			continue
+		if result.Status == StatusInvalid {
+			invalid = true
 		}
 
-		err := t.YouTrackRepository.SaveWorkLog(log)
+		results = append(results, result)
+	}
+
+	if rejected {
+		return results, WorkLogsRejected{results}
+	}
+
+	if invalid {
+		return results, WorkLogsInvalid{results}
+	}
+
+	if len(noIssueIdsFound.logs) > 0 {
+		return results, noIssueIdsFound
+	}
+
+	return results, nil
+}
+
+// syncWorkLog creates work item in issue repository unless ledger says it was
+// already created, in which case it is updated only if something changed.
+// Work log is then copied to destinations, if a required one fails what was
+// created is deleted again.
+func (t *TimeLoggerInteractor) syncWorkLog(log domain.IssueWorkLog) (SaveStatus, string, []DestinationResult, error) {
+	useLedger := t.WorkLogLedger != nil && log.EntryId != ""
+
+	var entry *domain.WorkLogLedgerEntry
+	var err error
+
+	if useLedger {
+		entry, err = t.WorkLogLedger.Find(log.EntryId, log.Date, log.IssueId)
 
 		if err != nil {
-			return err
+			return StatusRejected, "", nil, err
+		}
+	}
+
+	var workItemId string
+	var status SaveStatus
+
+	switch {
+	case entry == nil || !entry.Synced():
+		status = StatusSaved
+		workItemId, err = t.IssueRepository.SaveWorkLog(log)
+	case entry.Matches(log):
+		status = StatusAlreadySynced
+		workItemId = entry.WorkItemId
+	default:
+		status = StatusUpdated
+		workItemId = entry.WorkItemId
+		err = t.IssueRepository.UpdateWorkLog(workItemId, log)
+	}
+
+	if err != nil {
+		return StatusRejected, "", nil, err
+	}
+
+	var sinkItemIds map[string]string
+	sinkStatus := status
+
+	if entry != nil {
+		sinkItemIds = entry.SinkItemIds
+
+		// copies left after compensation are brought up to date with the log
+		if !entry.Synced() && !entry.Matches(log) {
+			sinkStatus = StatusUpdated
+		}
+	}
+
+	destinations, sinkItemIds, written := t.syncSinks(log, sinkStatus, sinkItemIds)
+
+	var syncErr error
+
+	if rejected := requiredRejected(destinations); len(rejected) > 0 {
+		status, sinkItemIds, syncErr = t.compensate(log, status, workItemId, destinations, sinkItemIds, rejected)
+	}
+
+	if status == StatusCompensated {
+		workItemId = ""
+
+		// copies which could not be deleted are remembered so they are not
+		// lost, earlier entry is overwritten even when nothing is left
+		if len(sinkItemIds) == 0 && entry == nil {
+			return status, "", destinations, syncErr
+		}
+	}
+
+	if !useLedger || (status == StatusAlreadySynced && !written) {
+		return status, workItemId, destinations, syncErr
+	}
+
+	synced := domain.WorkLogLedgerEntry{
+		EntryId:     log.EntryId,
+		Date:        log.Date.Format(domain.LedgerDateFormat),
+		IssueId:     log.IssueId,
+		WorkItemId:  workItemId,
+		SinkItemIds: sinkItemIds,
+		Duration:    log.Duration,
+		Type:        log.Type,
+		Description: log.Description,
+		SyncedAt:    time.Now(),
+	}
+
+	// copy which refused the update still has what was synced before, so
+	// the log is remembered as before and everything is updated next time
+	if entry != nil && updateRejected(destinations) {
+		synced.Duration = entry.Duration
+		synced.Type = entry.Type
+		synced.Description = entry.Description
+	}
+
+	// work item or its copies exist at this point, so ledger failure is
+	// only reported alongside the status
+	err = t.WorkLogLedger.Save(synced)
+
+	if syncErr != nil {
+		return status, workItemId, destinations, syncErr
+	}
+
+	return status, workItemId, destinations, err
+}
+
+func (t *TimeLoggerInteractor) issueIdOf(log SaveTimeLog) (string, error) {
+	if log.IssueId != "" {
+		return log.IssueId, nil
+	}
+
+	issueId, err := t.IssueIdExtractor.Extract(log.Title)
+
+	if err == nil || t.IssueMapper == nil {
+		return issueId, err
+	}
+
+	// only title is known here, rules matching anything else won't match
+	if mapping, ok := t.IssueMapper.Map(domain.TimeEntry{Title: log.Title, Date: log.Date}); ok {
+		return mapping.IssueId, nil
+	}
+
+	return "", err
+}
+
+// findLedgerEntries tells items what was already synced for them, reading
+// the ledger only once. keys are ledger keys of items, empty for unlinked ones.
+func (t *TimeLoggerInteractor) findLedgerEntries(items []LoggableItem, keys []string) error {
+	if t.WorkLogLedger == nil {
+		return nil
+	}
+
+	var wanted []string
+
+	for _, key := range keys {
+		if key != "" {
+			wanted = append(wanted, key)
+		}
+	}
+
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	entries, err := t.WorkLogLedger.FindAll(wanted)
+
+	if err != nil {
+		return NewError(ErrorInternal, err, "cannot read ledger")
+	}
+
+	for index, key := range keys {
+		// copies left by compensation don't make the entry logged
+		if entry := entries[key]; key != "" && entry != nil && entry.Synced() {
+			items[index].Ledger = entry
 		}
 	}
 
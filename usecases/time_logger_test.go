@@ -9,44 +9,150 @@ import (
 
 	"errors"
 
+	"sync"
+
 	"github.com/vizualni/meyougotrack/domain"
 	"github.com/vizualni/meyougotrack/interfaces"
 	"github.com/vizualni/meyougotrack/usecases"
 )
 
-type youtrackRepositoryMock struct {
-	findIssue   func(issueId string) (domain.YouTrackIssue, error)
-	saveWorkLog func(workLog domain.IssueWorkLog) error
+type issueRepositoryMock struct {
+	findIssue      func(issueId string) (domain.Issue, error)
+	saveWorkLog    func(workLog domain.IssueWorkLog) (string, error)
+	updateWorkLog  func(workItemId string, workLog domain.IssueWorkLog) error
+	deleteWorkLog  func(workItemId string, workLog domain.IssueWorkLog) error
+	previewWorkLog func(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error)
 }
 
-func (y *youtrackRepositoryMock) FindIssueByIssueId(issueId string) (domain.YouTrackIssue, error) {
+func (y *issueRepositoryMock) FindIssueByIssueId(issueId string) (domain.Issue, error) {
 	return y.findIssue(issueId)
 }
 
-func (y *youtrackRepositoryMock) SaveWorkLog(workLog domain.IssueWorkLog) error {
+func (y *issueRepositoryMock) SaveWorkLog(workLog domain.IssueWorkLog) (string, error) {
 	return y.saveWorkLog(workLog)
 }
 
-type trelloRepositoryMock struct {
-	getAllCards func(boardId string, doingListName string) []domain.TrelloCardDoingDuration
+func (y *issueRepositoryMock) UpdateWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return y.updateWorkLog(workItemId, workLog)
+}
+
+func (y *issueRepositoryMock) DeleteWorkLog(workItemId string, workLog domain.IssueWorkLog) error {
+	return y.deleteWorkLog(workItemId, workLog)
+}
+
+func (y *issueRepositoryMock) PreviewWorkLog(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+	return y.previewWorkLog(workItemId, workLog)
+}
+
+// workTypesRepositoryMock is issue repository which knows work types
+type workTypesRepositoryMock struct {
+	issueRepositoryMock
+	workTypes func(issueId string) ([]string, error)
+}
+
+func (y *workTypesRepositoryMock) WorkTypes(issueId string) ([]string, error) {
+	return y.workTypes(issueId)
+}
+
+type workLogLedgerMock struct {
+	entries map[string]domain.WorkLogLedgerEntry
+	findErr error
+	finds   int
+}
+
+func (l *workLogLedgerMock) Find(cardId string, date time.Time, issueId string) (*domain.WorkLogLedgerEntry, error) {
+	entry, ok := l.entries[domain.LedgerKey(cardId, date, issueId)]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (l *workLogLedgerMock) FindAll(keys []string) (map[string]*domain.WorkLogLedgerEntry, error) {
+	l.finds++
+	if l.findErr != nil {
+		return nil, l.findErr
+	}
+	entries := map[string]*domain.WorkLogLedgerEntry{}
+	for _, key := range keys {
+		if entry, ok := l.entries[key]; ok {
+			entries[key] = &entry
+		}
+	}
+	return entries, nil
+}
+
+func (l *workLogLedgerMock) Save(entry domain.WorkLogLedgerEntry) error {
+	l.entries[entry.Key()] = entry
+	return nil
+}
+
+type timeSourceMock struct {
+	getTimeEntries func() ([]domain.TimeEntry, error)
+}
+
+func (t *timeSourceMock) Name() string {
+	return "mock"
+}
+
+func (t *timeSourceMock) GetTimeEntries(filter domain.TimeEntryFilter, window domain.TimeWindow) ([]domain.TimeEntry, error) {
+	return t.getTimeEntries()
+}
+
+// defaultIssueSourceMock is time source with default issue, like ics
+type defaultIssueSourceMock struct {
+	timeSourceMock
+	name           string
+	defaultIssueId string
+}
+
+func (t *defaultIssueSourceMock) Name() string {
+	return t.name
+}
+
+func (t *defaultIssueSourceMock) DefaultIssueId() string {
+	return t.defaultIssueId
 }
 
-func (t *trelloRepositoryMock) GetAllCards(boardId string, doingListName string) []domain.TrelloCardDoingDuration {
-	return t.getAllCards(boardId, doingListName)
+type workLogSinkMock struct {
+	name          string
+	saveWorkLog   func(workLog domain.IssueWorkLog, issue domain.Issue) (string, error)
+	updateWorkLog func(itemId string, workLog domain.IssueWorkLog, issue domain.Issue) error
+	deleteWorkLog func(itemId string, workLog domain.IssueWorkLog) error
+}
+
+func (s *workLogSinkMock) Name() string {
+	return s.name
+}
+
+func (s *workLogSinkMock) SaveWorkLog(workLog domain.IssueWorkLog, issue domain.Issue) (string, error) {
+	return s.saveWorkLog(workLog, issue)
+}
+
+func (s *workLogSinkMock) UpdateWorkLog(itemId string, workLog domain.IssueWorkLog, issue domain.Issue) error {
+	return s.updateWorkLog(itemId, workLog, issue)
+}
+
+func (s *workLogSinkMock) DeleteWorkLog(itemId string, workLog domain.IssueWorkLog) error {
+	return s.deleteWorkLog(itemId, workLog)
 }
 
 func TestGetWithNoCardsReturned(t *testing.T) {
 	interactor := usecases.TimeLoggerInteractor{
-		IssueIdExtractor:   interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{},
-		TrelloRepository: &trelloRepositoryMock{
-			getAllCards: func(boardId string, doingListName string) []domain.TrelloCardDoingDuration {
-				return []domain.TrelloCardDoingDuration{}
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository:  &issueRepositoryMock{},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{}, nil
 			},
-		},
+		}},
 	}
 
-	linkedCards := interactor.GetTrelloYouTrackCards("something", "another")
+	linkedCards, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
 
 	if len(linkedCards) > 0 {
 		t.Fatal("Should have returned empty set")
@@ -57,28 +163,31 @@ func TestGetWithNoCardsReturned(t *testing.T) {
 func TestGetWithSingleCardReturned(t *testing.T) {
 	interactor := usecases.TimeLoggerInteractor{
 		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{
-			findIssue: func(issueId string) (domain.YouTrackIssue, error) {
-				t.Fatal("if I get called then something is wrong here")
-				return domain.YouTrackIssue{}, nil
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				t.Error("if I get called then something is wrong here")
+				return domain.Issue{}, nil
 			},
 		},
-		TrelloRepository: &trelloRepositoryMock{
-			getAllCards: func(boardId string, doingListName string) []domain.TrelloCardDoingDuration {
-				return []domain.TrelloCardDoingDuration{
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
 					{
-						Title:           "title",
-						Description:     "description",
-						Duration:        123,
-						Date:            time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
-						YoutrackSummary: "something",
+						Title:       "title",
+						Description: "description",
+						Duration:    123,
+						Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
 					},
-				}
+				}, nil
 			},
-		},
+		}},
 	}
 
-	linkedCards := interactor.GetTrelloYouTrackCards("something", "another")
+	linkedCards, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
 
 	if len(linkedCards) != 1 {
 		t.Fatal("Should have returned single item")
@@ -92,35 +201,36 @@ func TestGetWithSingleCardReturned(t *testing.T) {
 func TestGetWithSingleCardWithCorrectIssueIdReturned(t *testing.T) {
 	interactor := usecases.TimeLoggerInteractor{
 		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{
-			findIssue: func(issueId string) (domain.YouTrackIssue, error) {
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
 				if issueId != "MAT-123" {
-					t.Fatal("Incorrect issue id")
+					t.Error("Incorrect issue id")
 				}
-				return domain.YouTrackIssue{
-					Fields: []domain.Field{
-						{
-							Name: "summary", Value: "this title is from youtrack"},
-					},
+				return domain.Issue{
+					Id:      "MAT-123",
+					Summary: "this title is from youtrack",
 				}, nil
 			},
 		},
-		TrelloRepository: &trelloRepositoryMock{
-			getAllCards: func(boardId string, doingListName string) []domain.TrelloCardDoingDuration {
-				return []domain.TrelloCardDoingDuration{
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
 					{
-						Title:           "http://example.com/issue/MAT-123",
-						Description:     "description",
-						Duration:        123,
-						Date:            time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
-						YoutrackSummary: "something",
+						Title:       "http://example.com/issue/MAT-123",
+						Description: "description",
+						Duration:    123,
+						Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
 					},
-				}
+				}, nil
 			},
-		},
+		}},
 	}
 
-	linkedCards := interactor.GetTrelloYouTrackCards("something", "another")
+	linkedCards, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
 
 	if len(linkedCards) != 1 {
 		t.Fatal("Should have returned single item")
@@ -130,26 +240,166 @@ func TestGetWithSingleCardWithCorrectIssueIdReturned(t *testing.T) {
 		t.Fatal("It should have found issue")
 	}
 
-	if len(linkedCards[0].Issue.Fields) < 1 {
-		t.Fatal("It should have fields")
+	if linkedCards[0].Issue.Summary != "this title is from youtrack" {
+		t.Fatal("It should have summary")
+	}
+
+}
+
+func TestGetWithMissingIssueKeepsCardUnlinked(t *testing.T) {
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{}, usecases.NewError(usecases.ErrorNotFound, nil, "issue %s not found", issueId)
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
+					{Title: "http://example.com/issue/MAT-123", Duration: 123},
+				}, nil
+			},
+		}},
+	}
+
+	linkedCards, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal("Missing issue should not fail whole list", err)
+	}
+
+	if len(linkedCards) != 1 || linkedCards[0].Issue != nil {
+		t.Fatal("Expected single card without issue")
+	}
+}
+
+func TestGetWhenYouTrackIsUnavailable(t *testing.T) {
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{}, usecases.NewError(usecases.ErrorUpstreamUnavailable, errors.New("connection refused"), "youtrack unreachable")
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
+					{Title: "http://example.com/issue/MAT-123", Duration: 123},
+				}, nil
+			},
+		}},
+	}
+
+	_, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if usecases.KindOf(err) != usecases.ErrorUpstreamUnavailable {
+		t.Fatal("Expected upstream unavailable error", err)
+	}
+}
+
+func TestGetMergesEntriesFromAllTimeSources(t *testing.T) {
+	source := func(title string) usecases.TimeSource {
+		return &timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{{Title: title, Duration: 10}}, nil
+			},
+		}
+	}
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{
+			source("http://example.com/issue/MAT-1"),
+			source("http://example.com/issue/MAT-2"),
+		},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	if len(items) != 2 || items[0].Issue.Id != "MAT-1" || items[1].Issue.Id != "MAT-2" {
+		t.Fatal("Expected entries of both sources in order", items)
+	}
+}
+
+func TestGetAndSaveUseIssueIdGivenBySource(t *testing.T) {
+	var saved domain.IssueWorkLog
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				saved = workLog
+				return "1-1", nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{{IssueId: "MAT-9", Title: "no url here", Duration: 10}}, nil
+			},
+		}},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil || items[0].Issue == nil || items[0].Issue.Id != "MAT-9" {
+		t.Fatal("Expected issue given by source to be linked", items, err)
+	}
+
+	_, err = interactor.SaveWorklogs([]usecases.SaveTimeLog{{IssueId: "MAT-9", Title: "no url here", Duration: 10}})
+
+	if err != nil || saved.IssueId != "MAT-9" {
+		t.Fatal("Expected work log saved to issue given by source", saved, err)
+	}
+}
+
+func TestGetWhenTimeSourceFails(t *testing.T) {
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository:  &issueRepositoryMock{},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return nil, usecases.NewError(usecases.ErrorAuthFailed, errors.New("401"), "cannot get trello board %s", "board1")
+			},
+		}},
+	}
+
+	linkedCards, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if usecases.KindOf(err) != usecases.ErrorAuthFailed {
+		t.Fatal("Expected time source error to be returned", err)
 	}
 
+	if linkedCards != nil {
+		t.Fatal("Expected no cards on error")
+	}
 }
 
 func TestSaveWithZeroCards(t *testing.T) {
 	interactor := usecases.TimeLoggerInteractor{
 		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{
-			saveWorkLog: func(workLog domain.IssueWorkLog) error {
+		IssueRepository: &issueRepositoryMock{
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
 				t.Fatal("This should not have been called since nothing is being saved", workLog)
-				return nil
+				return "", nil
 			},
 		},
-		TrelloRepository: &trelloRepositoryMock{},
 	}
 	var logs []usecases.SaveTimeLog
 
-	err := interactor.SaveWorklogs(logs)
+	_, err := interactor.SaveWorklogs(logs)
 
 	if err != nil {
 		t.Fatal("No error expected", err)
@@ -161,13 +411,12 @@ func TestSaveWithMultipleCards(t *testing.T) {
 	var numberOfExpectedCalls = 2
 	interactor := usecases.TimeLoggerInteractor{
 		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{
-			saveWorkLog: func(workLog domain.IssueWorkLog) error {
+		IssueRepository: &issueRepositoryMock{
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
 				numberOfExpectedCalls--
-				return nil
+				return "1-1", nil
 			},
 		},
-		TrelloRepository: &trelloRepositoryMock{},
 	}
 
 	logs := []usecases.SaveTimeLog{
@@ -187,7 +436,7 @@ func TestSaveWithMultipleCards(t *testing.T) {
 		},
 	}
 
-	err := interactor.SaveWorklogs(logs)
+	_, err := interactor.SaveWorklogs(logs)
 
 	if numberOfExpectedCalls != 0 {
 		t.Fatal("Expected to call save 2 times")
@@ -199,12 +448,11 @@ func TestSaveWithMultipleCards(t *testing.T) {
 func TestSaveWithInvalidCardTitle(t *testing.T) {
 	interactor := usecases.TimeLoggerInteractor{
 		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{
-			saveWorkLog: func(workLog domain.IssueWorkLog) error {
-				return nil
+		IssueRepository: &issueRepositoryMock{
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				return "1-1", nil
 			},
 		},
-		TrelloRepository: &trelloRepositoryMock{},
 	}
 	var logs = []usecases.SaveTimeLog{
 		{
@@ -230,12 +478,24 @@ func TestSaveWithInvalidCardTitle(t *testing.T) {
 		},
 	}
 
-	err := interactor.SaveWorklogs(logs)
+	results, err := interactor.SaveWorklogs(logs)
 
 	if _, ok := err.(usecases.NoIssueIdFound); !ok {
 		t.Fatal("Expected to have card with no issue id found")
 	}
 
+	if len(results) != 3 {
+		t.Fatal("Expected result for every log")
+	}
+
+	if results[0].Status != usecases.StatusNoIssueId || results[1].Status != usecases.StatusNoIssueId {
+		t.Fatal("Expected first two logs to have no issue id")
+	}
+
+	if results[2].Status != usecases.StatusSaved || results[2].WorkItemId != "1-1" {
+		t.Fatal("Expected last log to be saved anyway", results[2])
+	}
+
 	fmt.Println(err)
 
 }
@@ -243,12 +503,14 @@ func TestSaveWithInvalidCardTitle(t *testing.T) {
 func TestSaveWhenRepositoryReturnsError(t *testing.T) {
 	interactor := usecases.TimeLoggerInteractor{
 		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
-		YouTrackRepository: &youtrackRepositoryMock{
-			saveWorkLog: func(workLog domain.IssueWorkLog) error {
-				return errors.New("you didn't expect this. didnt you?")
+		IssueRepository: &issueRepositoryMock{
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				if workLog.IssueId == "MAT-456" {
+					return "", errors.New("you didn't expect this. didnt you?")
+				}
+				return "1-1", nil
 			},
 		},
-		TrelloRepository: &trelloRepositoryMock{},
 	}
 	var logs = []usecases.SaveTimeLog{
 		{
@@ -258,10 +520,1057 @@ func TestSaveWhenRepositoryReturnsError(t *testing.T) {
 			WorkType:    "Work",
 			Duration:    123,
 		},
+		{
+			Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
+			Title:       "http://example.com/issue/MAT-123",
+			Description: "lalalla",
+			WorkType:    "Work",
+			Duration:    0,
+		},
+		{
+			Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
+			Title:       "http://example.com/issue/MAT-789",
+			Description: "lalalla",
+			WorkType:    "Work",
+			Duration:    123,
+		},
+	}
+
+	results, err := interactor.SaveWorklogs(logs)
+
+	if _, ok := err.(usecases.WorkLogsRejected); !ok {
+		t.Fatal("Expected rejected work logs error", err)
+	}
+
+	if results[0].Status != usecases.StatusRejected || results[0].Reason == "" {
+		t.Fatal("Expected first log to be rejected with reason", results[0])
+	}
+
+	if results[1].Status != usecases.StatusSkippedZeroDuration {
+		t.Fatal("Expected zero duration log to be skipped", results[1])
+	}
+
+	if results[2].Status != usecases.StatusSaved {
+		t.Fatal("Expected save to continue after rejected log", results[2])
+	}
+
+}
+
+func TestSaveSameCardTwiceLogsOnlyOnce(t *testing.T) {
+	var numberOfSaveCalls = 0
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				numberOfSaveCalls++
+				return "1-1", nil
+			},
+			updateWorkLog: func(workItemId string, workLog domain.IssueWorkLog) error {
+				t.Fatal("Nothing changed so nothing should be updated")
+				return nil
+			},
+		},
+		WorkLogLedger: &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{}},
+	}
+
+	logs := []usecases.SaveTimeLog{
+		{
+			EntryId:     "card1",
+			Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
+			Title:       "http://example.com/issue/MAT-123",
+			Description: "lalalla",
+			WorkType:    "Work",
+			Duration:    123,
+		},
 	}
 
 	interactor.SaveWorklogs(logs)
+	interactor.SaveWorklogs(logs)
+
+	if numberOfSaveCalls != 1 {
+		t.Fatal("Expected to call save only once", numberOfSaveCalls)
+	}
+}
+
+func TestSaveChangedCardUpdatesExistingWorkItem(t *testing.T) {
+	var updatedWorkItemId string
+	ledger := &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{}}
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				return "1-1", nil
+			},
+			updateWorkLog: func(workItemId string, workLog domain.IssueWorkLog) error {
+				updatedWorkItemId = workItemId
+				return nil
+			},
+		},
+		WorkLogLedger: ledger,
+	}
+
+	log := usecases.SaveTimeLog{
+		EntryId:     "card1",
+		Date:        time.Date(2018, 1, 1, 1, 1, 1, 1, time.UTC),
+		Title:       "http://example.com/issue/MAT-123",
+		Description: "lalalla",
+		WorkType:    "Work",
+		Duration:    123,
+	}
+
+	interactor.SaveWorklogs([]usecases.SaveTimeLog{log})
+
+	log.Duration = 60
+
+	interactor.SaveWorklogs([]usecases.SaveTimeLog{log})
+
+	if updatedWorkItemId != "1-1" {
+		t.Fatal("Expected existing work item to be updated")
+	}
+
+	entry, _ := ledger.Find("card1", log.Date, "MAT-123")
+
+	if entry == nil || entry.Duration != 60 {
+		t.Fatal("Expected ledger to hold updated duration")
+	}
+}
+
+func TestGetLooksUpEachIssueOnlyOnce(t *testing.T) {
+	var mutex sync.Mutex
+	lookups := map[string]int{}
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				mutex.Lock()
+				lookups[issueId]++
+				mutex.Unlock()
+				return domain.Issue{Id: issueId}, nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
+					{Title: "http://example.com/issue/MAT-1"},
+					{Title: "http://example.com/issue/MAT-2"},
+					{Title: "http://example.com/issue/MAT-1"},
+					{Title: "no issue"},
+					{Title: "http://example.com/issue/MAT-2"},
+				}, nil
+			},
+		}},
+		IssueLookupWorkers: 3,
+	}
+
+	linkedCards, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lookups) != 2 || lookups["MAT-1"] != 1 || lookups["MAT-2"] != 1 {
+		t.Fatal("Expected every issue to be looked up once", lookups)
+	}
+
+	expected := []string{"MAT-1", "MAT-2", "MAT-1", "", "MAT-2"}
+
+	for i, issueId := range expected {
+		if issueId == "" {
+			if linkedCards[i].Issue != nil {
+				t.Fatal("Card without issue id should not be linked")
+			}
+			continue
+		}
+
+		if linkedCards[i].Issue == nil || linkedCards[i].Issue.Id != issueId {
+			t.Fatal("Card linked to wrong issue", i)
+		}
+	}
+}
+
+func TestSaveCopiesWorkLogToOptionalSinks(t *testing.T) {
+	togglFails := true
+	togglSaves := 0
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId, Project: domain.Project{ShortName: "MAT"}}, nil
+			},
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				return "1-1", nil
+			},
+		},
+		WorkLogLedger: &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{}},
+		Destinations: []usecases.Destination{{
+			Sink: &workLogSinkMock{
+				name: "toggl",
+				saveWorkLog: func(workLog domain.IssueWorkLog, issue domain.Issue) (string, error) {
+					togglSaves++
+					if issue.Project.ShortName != "MAT" || workLog.Title != "http://example.com/issue/MAT-1" {
+						t.Fatal("Sink should get the issue and the title", issue, workLog)
+					}
+					if togglFails {
+						return "", usecases.NewError(usecases.ErrorUpstreamUnavailable, nil, "toggl failed")
+					}
+					return "42", nil
+				},
+			},
+		}},
+	}
+
+	logs := []usecases.SaveTimeLog{{
+		EntryId:  "card1",
+		Title:    "http://example.com/issue/MAT-1",
+		Duration: 30,
+		Date:     time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+	}}
+
+	results, err := interactor.SaveWorklogs(logs)
+
+	if err != nil {
+		t.Fatal("Optional sink failure should only be reported", err)
+	}
+
+	if results[0].Status != usecases.StatusSaved || len(results[0].Destinations) != 1 || results[0].Destinations[0].Status != usecases.StatusRejected {
+		t.Fatal("Expected saved log with rejected destination", results)
+	}
+
+	togglFails = false
+
+	results, err = interactor.SaveWorklogs(logs)
+
+	if err != nil {
+		t.Fatal("No error expected", err)
+	}
+
+	destination := results[0].Destinations[0]
+
+	if results[0].Status != usecases.StatusAlreadySynced || destination.Status != usecases.StatusSaved || destination.ItemId != "42" {
+		t.Fatal("Expected failed sink to be retried", results)
+	}
+
+	results, _ = interactor.SaveWorklogs(logs)
+
+	if results[0].Destinations[0].Status != usecases.StatusAlreadySynced || togglSaves != 2 {
+		t.Fatal("Expected sink to be written only once", results, togglSaves)
+	}
+}
 
-	// todo
+func TestSaveChangedLogUpdatesSinks(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	updatedItem := ""
 
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			updateWorkLog: func(workItemId string, workLog domain.IssueWorkLog) error {
+				return nil
+			},
+		},
+		WorkLogLedger: &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{
+			domain.LedgerKey("card1", date, "MAT-1"): {
+				EntryId:     "card1",
+				Date:        "2018-01-01",
+				IssueId:     "MAT-1",
+				WorkItemId:  "1-1",
+				SinkItemIds: map[string]string{"toggl": "42"},
+				Duration:    30,
+			},
+		}},
+		Destinations: []usecases.Destination{{
+			Sink: &workLogSinkMock{
+				name: "toggl",
+				updateWorkLog: func(itemId string, workLog domain.IssueWorkLog, issue domain.Issue) error {
+					updatedItem = itemId
+					return nil
+				},
+			},
+			Required: true,
+		}},
+	}
+
+	results, err := interactor.SaveWorklogs([]usecases.SaveTimeLog{{
+		EntryId:  "card1",
+		Title:    "http://example.com/issue/MAT-1",
+		Duration: 45,
+		Date:     date,
+	}})
+
+	if err != nil || results[0].Status != usecases.StatusUpdated || results[0].Destinations[0].Status != usecases.StatusUpdated || updatedItem != "42" {
+		t.Fatal("Expected sink copy to be updated", results, err)
+	}
+}
+
+func TestSaveRetriesUpdateRequiredSinkRejected(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	togglFails := true
+	var togglDurations []int
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			updateWorkLog: func(workItemId string, workLog domain.IssueWorkLog) error {
+				return nil
+			},
+		},
+		WorkLogLedger: &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{
+			domain.LedgerKey("card1", date, "MAT-1"): {
+				EntryId:     "card1",
+				Date:        "2018-01-01",
+				IssueId:     "MAT-1",
+				WorkItemId:  "1-1",
+				SinkItemIds: map[string]string{"toggl": "42"},
+				Duration:    30,
+			},
+		}},
+		Destinations: []usecases.Destination{{
+			Sink: &workLogSinkMock{
+				name: "toggl",
+				updateWorkLog: func(itemId string, workLog domain.IssueWorkLog, issue domain.Issue) error {
+					if togglFails {
+						return errors.New("toggl is down")
+					}
+					togglDurations = append(togglDurations, workLog.Duration)
+					return nil
+				},
+			},
+			Required: true,
+		}},
+	}
+
+	logs := []usecases.SaveTimeLog{{
+		EntryId:  "card1",
+		Title:    "http://example.com/issue/MAT-1",
+		Duration: 45,
+		Date:     date,
+	}}
+
+	results, err := interactor.SaveWorklogs(logs)
+
+	if _, ok := err.(usecases.WorkLogsRejected); !ok || results[0].Destinations[0].Status != usecases.StatusRejected {
+		t.Fatal("Expected rejected update", results, err)
+	}
+
+	togglFails = false
+
+	results, err = interactor.SaveWorklogs(logs)
+
+	if err != nil || results[0].Status != usecases.StatusUpdated || results[0].Destinations[0].Status != usecases.StatusUpdated {
+		t.Fatal("Expected rejected update to be retried", results, err)
+	}
+
+	if len(togglDurations) != 1 || togglDurations[0] != 45 {
+		t.Fatal("Expected copy to get the new duration", togglDurations)
+	}
+
+	results, _ = interactor.SaveWorklogs(logs)
+
+	if results[0].Status != usecases.StatusAlreadySynced || len(togglDurations) != 1 {
+		t.Fatal("Expected log to be synced once everything is updated", results, togglDurations)
+	}
+}
+
+func TestSaveCompensatesWhenRequiredSinkFails(t *testing.T) {
+	var deleted []string
+	deleteFails := false
+
+	ledger := &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{}}
+
+	sink := func(name string, fails bool) *workLogSinkMock {
+		return &workLogSinkMock{
+			name: name,
+			saveWorkLog: func(workLog domain.IssueWorkLog, issue domain.Issue) (string, error) {
+				if fails {
+					return "", usecases.NewError(usecases.ErrorValidation, nil, "%s refused", name)
+				}
+				return name + "-1", nil
+			},
+			deleteWorkLog: func(itemId string, workLog domain.IssueWorkLog) error {
+				if deleteFails {
+					return errors.New("cannot delete")
+				}
+				deleted = append(deleted, itemId)
+				return nil
+			},
+		}
+	}
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				return "1-1", nil
+			},
+			deleteWorkLog: func(workItemId string, workLog domain.IssueWorkLog) error {
+				deleted = append(deleted, workItemId)
+				return nil
+			},
+		},
+		WorkLogLedger: ledger,
+		Destinations: []usecases.Destination{
+			{Sink: sink("clockify", false)},
+			{Sink: sink("toggl", true), Required: true},
+		},
+	}
+
+	logs := []usecases.SaveTimeLog{{
+		EntryId:  "card1",
+		Title:    "http://example.com/issue/MAT-1",
+		Duration: 30,
+		Date:     time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+	}}
+
+	results, err := interactor.SaveWorklogs(logs)
+
+	if _, ok := err.(usecases.WorkLogsRejected); !ok {
+		t.Fatal("Expected rejected error when required sink fails", err)
+	}
+
+	result := results[0]
+
+	if result.Status != usecases.StatusCompensated || result.WorkItemId != "" || result.Reason != "required destination toggl rejected the log" {
+		t.Fatal("Expected work item to be compensated", result)
+	}
+
+	if result.Destinations[0].Status != usecases.StatusCompensated || result.Destinations[1].Status != usecases.StatusRejected {
+		t.Fatal("Unexpected destinations", result.Destinations)
+	}
+
+	if len(deleted) != 2 || deleted[0] != "clockify-1" || deleted[1] != "1-1" {
+		t.Fatal("Expected copies and work item to be deleted", deleted)
+	}
+
+	if len(ledger.entries) != 0 {
+		t.Fatal("Compensated log should not be remembered", ledger.entries)
+	}
+
+	deleteFails = true
+
+	results, _ = interactor.SaveWorklogs(logs)
+
+	if results[0].Status != usecases.StatusCompensated || results[0].Destinations[0].Status != usecases.StatusCompensationFailed {
+		t.Fatal("Expected failed compensation to be reported", results)
+	}
+
+	if results[0].Reason != "required destination toggl rejected the log, could not undo clockify" {
+		t.Fatal("Unexpected reason", results[0].Reason)
+	}
+
+	entry := ledger.entries[domain.LedgerKey("card1", logs[0].Date, "MAT-1")]
+
+	if entry.WorkItemId != "" || entry.SinkItemIds["clockify"] != "clockify-1" {
+		t.Fatal("Expected copy which was not deleted to be remembered", ledger.entries)
+	}
+
+	deleteFails = false
+	deleted = nil
+
+	results, _ = interactor.SaveWorklogs(logs)
+
+	if results[0].Status != usecases.StatusCompensated || results[0].Destinations[0].Status != usecases.StatusCompensated {
+		t.Fatal("Expected remembered copy to be compensated again", results)
+	}
+
+	if len(deleted) != 2 || deleted[0] != "clockify-1" || deleted[1] != "1-1" {
+		t.Fatal("Expected remembered copy and work item to be deleted", deleted)
+	}
+
+	entry = ledger.entries[domain.LedgerKey("card1", logs[0].Date, "MAT-1")]
+
+	if entry.WorkItemId != "" || len(entry.SinkItemIds) != 0 {
+		t.Fatal("Expected nothing to be remembered once everything is deleted", ledger.entries)
+	}
+}
+
+func TestSaveReportsWorkItemWhichCouldNotBeCompensated(t *testing.T) {
+	ledger := &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{}}
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				return "1-1", nil
+			},
+			deleteWorkLog: func(workItemId string, workLog domain.IssueWorkLog) error {
+				return errors.New("cannot delete")
+			},
+		},
+		WorkLogLedger: ledger,
+		Destinations: []usecases.Destination{{
+			Sink: &workLogSinkMock{
+				name: "toggl",
+				saveWorkLog: func(workLog domain.IssueWorkLog, issue domain.Issue) (string, error) {
+					return "", errors.New("toggl refused")
+				},
+			},
+			Required: true,
+		}},
+	}
+
+	results, err := interactor.SaveWorklogs([]usecases.SaveTimeLog{{
+		EntryId:  "card1",
+		Title:    "http://example.com/issue/MAT-1",
+		Duration: 30,
+		Date:     date,
+	}})
+
+	if _, ok := err.(usecases.WorkLogsRejected); !ok {
+		t.Fatal("Expected rejected error", err)
+	}
+
+	if results[0].Status != usecases.StatusCompensationFailed || results[0].WorkItemId != "1-1" {
+		t.Fatal("Expected failed compensation of work item", results)
+	}
+
+	if results[0].Reason != "required destination toggl rejected the log, could not undo work item 1-1: cannot delete" {
+		t.Fatal("Unexpected reason", results[0].Reason)
+	}
+
+	if ledger.entries[domain.LedgerKey("card1", date, "MAT-1")].WorkItemId != "1-1" {
+		t.Fatal("Expected work item which is still there to be remembered", ledger.entries)
+	}
+}
+
+func TestPreviewValidatesLogsWithoutWritingAnything(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			previewWorkLog: func(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+				if workLog.Type == "Sleep" {
+					return usecases.WorkLogRequest{}, usecases.NewError(usecases.ErrorValidation, nil, "unknown work type")
+				}
+				return usecases.WorkLogRequest{Method: "POST", Url: "/issues/" + workLog.IssueId + "/" + workItemId}, nil
+			},
+		},
+		WorkLogLedger: &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{
+			domain.LedgerKey("card2", date, "MAT-2"): {EntryId: "card2", Date: "2018-01-01", IssueId: "MAT-2", WorkItemId: "2-1", Duration: 30},
+		}},
+		Destinations: []usecases.Destination{{Sink: &workLogSinkMock{name: "toggl"}, Required: true}},
+	}
+
+	results, err := interactor.PreviewWorklogs([]usecases.SaveTimeLog{
+		{EntryId: "card1", IssueId: "MAT-1", Duration: 30, Date: date},
+		{EntryId: "card2", IssueId: "MAT-2", Duration: 45, Date: date},
+		{EntryId: "card2", IssueId: "MAT-2", Duration: 30, Date: date},
+		{EntryId: "card3", IssueId: "MAT-3", Duration: 30, Date: date, WorkType: "Sleep"},
+	})
+
+	if _, ok := err.(usecases.WorkLogsInvalid); !ok || usecases.KindOf(err) != usecases.ErrorPartialFailure {
+		t.Fatal("Expected invalid work logs", err)
+	}
+
+	expected := []usecases.SaveStatus{usecases.StatusWouldSave, usecases.StatusWouldUpdate, usecases.StatusAlreadySynced, usecases.StatusInvalid}
+
+	for index, result := range results {
+		if result.Status != expected[index] {
+			t.Fatal("Unexpected status", index, result)
+		}
+	}
+
+	if results[0].Request == nil || results[0].Request.Url != "/issues/MAT-1/" || results[1].Request.Url != "/issues/MAT-2/2-1" {
+		t.Fatal("Expected would-be requests", results)
+	}
+
+	if !results[3].Failed() || results[3].Rejected() {
+		t.Fatal("Invalid log should fail without being rejected", results[3])
+	}
+}
+
+func TestPreviewAndGetTreatCompensationLeftoverAsNotSynced(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	var previewedWorkItemId *string
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			previewWorkLog: func(workItemId string, workLog domain.IssueWorkLog) (usecases.WorkLogRequest, error) {
+				previewedWorkItemId = &workItemId
+				return usecases.WorkLogRequest{Method: "POST", Url: "/issues/" + workLog.IssueId + "/" + workItemId}, nil
+			},
+		},
+		WorkLogLedger: &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{
+			domain.LedgerKey("card1", date, "MAT-1"): {
+				EntryId:     "card1",
+				Date:        "2018-01-01",
+				IssueId:     "MAT-1",
+				SinkItemIds: map[string]string{"toggl": "42"},
+				Duration:    30,
+			},
+		}},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{{Id: "card1", Title: "http://example.com/issue/MAT-1", Duration: 30, Date: date}}, nil
+			},
+		}},
+	}
+
+	results, err := interactor.PreviewWorklogs([]usecases.SaveTimeLog{{
+		EntryId:  "card1",
+		Title:    "http://example.com/issue/MAT-1",
+		Duration: 30,
+		Date:     date,
+	}})
+
+	if err != nil || results[0].Status != usecases.StatusWouldSave || results[0].WorkItemId != "" {
+		t.Fatal("Expected leftover of compensation to be saved again", results, err)
+	}
+
+	if previewedWorkItemId == nil || *previewedWorkItemId != "" || results[0].Request.Url != "/issues/MAT-1/" {
+		t.Fatal("Expected request creating a new work item", results[0].Request)
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil || items[0].Ledger != nil {
+		t.Fatal("Expected item not to be shown as logged", items[0].Ledger, err)
+	}
+}
+
+func TestGetReadsLedgerOnceAndReportsItsErrors(t *testing.T) {
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	ledger := &workLogLedgerMock{entries: map[string]domain.WorkLogLedgerEntry{
+		domain.LedgerKey("card1", date, "MAT-1"): {EntryId: "card1", Date: "2018-01-01", IssueId: "MAT-1", WorkItemId: "1-1"},
+	}}
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+		},
+		WorkLogLedger: ledger,
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
+					{Id: "card1", Title: "http://example.com/issue/MAT-1", Duration: 30, Date: date},
+					{Id: "card2", Title: "http://example.com/issue/MAT-2", Duration: 30, Date: date},
+					{Id: "card3", Title: "no issue", Duration: 30, Date: date},
+				}, nil
+			},
+		}},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil || len(items) != 3 || items[0].Ledger == nil || items[1].Ledger != nil || items[2].Ledger != nil {
+		t.Fatal("Expected only first item to be logged", items, err)
+	}
+
+	if ledger.finds != 1 {
+		t.Fatal("Expected ledger to be read once, got", ledger.finds)
+	}
+
+	ledger.findErr = errors.New("timeout")
+
+	_, err = interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if usecases.KindOf(err) != usecases.ErrorInternal {
+		t.Fatal("Expected ledger error to be reported", err)
+	}
+}
+
+func splitItems(t *testing.T, title string, description string, duration int64) []usecases.LoggableItem {
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.NewIssueKeyExtractor(true, []string{"ABC"}, nil, time.Minute),
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{{Id: "card1", Title: title, Description: description, Duration: duration}}, nil
+			},
+		}},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return items
+}
+
+func assertSplit(t *testing.T, items []usecases.LoggableItem, issueIds []string, durations []int64, rule string) {
+	if len(items) != len(issueIds) {
+		t.Fatal("Unexpected number of items", len(items))
+	}
+
+	for index, item := range items {
+		if item.Issue == nil || item.Issue.Id != issueIds[index] || item.Entry.IssueId != issueIds[index] {
+			t.Fatal("Unexpected issue", index, item.Entry.IssueId)
+		}
+
+		if item.Entry.Duration != durations[index] || item.Entry.Split == nil || item.Entry.Split.Rule != rule {
+			t.Fatalf("Unexpected split %d %d %+v", index, item.Entry.Duration, item.Entry.Split)
+		}
+	}
+}
+
+func TestGetSplitsEntryEvenlyAcrossIssues(t *testing.T) {
+	items := splitItems(t, "ABC-1 and ABC-2 and ABC-3, ABC-1 again", "", 100)
+
+	assertSplit(t, items, []string{"ABC-1", "ABC-2", "ABC-3"}, []int64{34, 33, 33}, domain.SplitEven)
+
+	if items[0].Entry.Split.Total != 100 || items[0].Entry.Id != "card1" {
+		t.Fatal("Parts should remember the whole entry", items[0].Entry)
+	}
+}
+
+func TestGetSplitsEntryByAnnotations(t *testing.T) {
+	items := splitItems(t, "ABC-1 login", "ABC-1:1h15m ABC-2: 30m", 120)
+
+	assertSplit(t, items, []string{"ABC-1", "ABC-2"}, []int64{75, 30}, domain.SplitAnnotation)
+}
+
+func TestGetSplitsRestOfEntryByWeights(t *testing.T) {
+	items := splitItems(t, "ABC-1 ABC-2 ABC-3", "ABC-1:30m ABC-2:2", 120)
+
+	if items[0].Entry.Duration != 30 || items[0].Entry.Split.Rule != domain.SplitAnnotation {
+		t.Fatal("Unexpected annotated part", items[0].Entry)
+	}
+
+	assertSplit(t, items[1:], []string{"ABC-2", "ABC-3"}, []int64{60, 30}, domain.SplitWeight)
+
+	if items[1].Entry.Split.Weight != 2 || items[2].Entry.Split.Weight != 1 {
+		t.Fatal("Unexpected weights", items[1].Entry.Split, items[2].Entry.Split)
+	}
+}
+
+func TestGetIgnoresAnnotationsOfUnknownIssues(t *testing.T) {
+	items := splitItems(t, "ABC-1 ABC-2", "UTF-8: 2, SHA-256: 1h", 60)
+
+	assertSplit(t, items, []string{"ABC-1", "ABC-2"}, []int64{30, 30}, domain.SplitEven)
+}
+
+func TestGetScalesAnnotationsDownToEntry(t *testing.T) {
+	items := splitItems(t, "ABC-1 ABC-2 ABC-3", "ABC-1:1h ABC-2:50m", 100)
+
+	assertSplit(t, items[:2], []string{"ABC-1", "ABC-2"}, []int64{55, 45}, domain.SplitAnnotation)
+
+	if items[2].Entry.Duration != 0 {
+		t.Fatal("Expected nothing to be left for the rest", items[2].Entry)
+	}
+}
+
+func TestGetDoesNotSplitEntryWithSingleIssue(t *testing.T) {
+	items := splitItems(t, "ABC-1 login, see ABC-1", "took ABC-1:30m", 60)
+
+	if len(items) != 1 || items[0].Entry.Split != nil || items[0].Entry.Duration != 60 || items[0].Issue.Id != "ABC-1" {
+		t.Fatal("Entry should not be split", items[0].Entry)
+	}
+}
+
+func TestGetAndSaveMapEntriesWithoutIssueIdByRules(t *testing.T) {
+	rules, err := interfaces.ParseIssueRules([]byte(`
+- name: standup
+  title: '(?i)standup'
+  issue: MAT-1
+  worktype: Meeting
+  description: 'Standup {{.Date.Format "02.01."}}'
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var savedWorkLog domain.IssueWorkLog
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueMapper:      rules,
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+			saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+				savedWorkLog = workLog
+				return "1-1", nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
+					{Id: "ics:1", Title: "Daily standup", WorkType: "Work", Duration: 15, Date: time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+					{Id: "card1", Title: "no issue", Duration: 30},
+				}, nil
+			},
+		}},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	standup := items[0].Entry
+
+	if items[0].Issue == nil || standup.IssueId != "MAT-1" || standup.MappedBy != "standup" {
+		t.Fatal("Expected standup to be mapped", standup)
+	}
+
+	if standup.WorkType != "Meeting" || standup.Description != "Standup 02.01." {
+		t.Fatal("Expected rule to set worktype and description", standup)
+	}
+
+	if items[1].Issue != nil || items[1].Entry.MappedBy != "" {
+		t.Fatal("Expected entry matching no rule to stay unlinked", items[1].Entry)
+	}
+
+	results, err := interactor.SaveWorklogs([]usecases.SaveTimeLog{
+		{Title: "Standup", WorkType: "Meeting", Duration: 15, Date: time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+	})
+
+	if err != nil || results[0].Status != usecases.StatusSaved || savedWorkLog.IssueId != "MAT-1" {
+		t.Fatal("Expected log without issue id to be saved to mapped issue", results, err)
+	}
+}
+
+func TestGetMapsEntriesByRulesBeforeDefaultIssueOfSource(t *testing.T) {
+	rules, err := interfaces.ParseIssueRules([]byte(`
+- name: calendar standup
+  source: ics
+  title: '(?i)standup'
+  issue: MAT-5
+`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.SimpleRegexIssueIdExtractor{},
+		IssueMapper:      rules,
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{
+			&defaultIssueSourceMock{
+				name:           "ics",
+				defaultIssueId: "MAT-1",
+				timeSourceMock: timeSourceMock{
+					getTimeEntries: func() ([]domain.TimeEntry, error) {
+						return []domain.TimeEntry{
+							{Id: "ics:1", Source: "ics", Title: "Daily standup", Duration: 15},
+							{Id: "ics:2", Source: "ics", Title: "Lunch with team", Duration: 60},
+						}, nil
+					},
+				},
+			},
+			&timeSourceMock{
+				getTimeEntries: func() ([]domain.TimeEntry, error) {
+					return []domain.TimeEntry{{Id: "card1", Source: "mock", Title: "no issue", Duration: 30}}, nil
+				},
+			},
+		},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items[0].Issue == nil || items[0].Entry.IssueId != "MAT-5" || items[0].Entry.MappedBy != "calendar standup" {
+		t.Fatal("Expected rule to win over default issue", items[0].Entry)
+	}
+
+	if items[1].Issue == nil || items[1].Entry.IssueId != "MAT-1" || items[1].Entry.MappedBy != "" {
+		t.Fatal("Expected default issue when no rule matches", items[1].Entry)
+	}
+
+	if items[2].Issue != nil || items[2].Entry.IssueId != "" {
+		t.Fatal("Expected source without default issue to stay unlinked", items[2].Entry)
+	}
+}
+
+func TestSaveRejectsWorkTypesIssueDoesNotAccept(t *testing.T) {
+	var savedTypes []string
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueRepository: &workTypesRepositoryMock{
+			issueRepositoryMock: issueRepositoryMock{
+				saveWorkLog: func(workLog domain.IssueWorkLog) (string, error) {
+					savedTypes = append(savedTypes, workLog.Type)
+					return "1-1", nil
+				},
+			},
+			workTypes: func(issueId string) ([]string, error) {
+				if issueId == "MAT-500" {
+					return nil, usecases.NewError(usecases.ErrorUpstreamUnavailable, nil, "youtrack failed")
+				}
+				return []string{"Development", "Meeting"}, nil
+			},
+		},
+	}
+
+	date := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	results, err := interactor.SaveWorklogs([]usecases.SaveTimeLog{
+		{IssueId: "MAT-1", Duration: 30, Date: date, WorkType: "meeting"},
+		{IssueId: "MAT-1", Duration: 30, Date: date, WorkType: "Work"},
+		{IssueId: "MAT-1", Duration: 30, Date: date},
+	})
+
+	if _, ok := err.(usecases.WorkLogsInvalid); !ok {
+		t.Fatal("Expected invalid work logs", err)
+	}
+
+	if results[0].Status != usecases.StatusSaved || results[2].Status != usecases.StatusSaved {
+		t.Fatal("Expected known and missing work types to be saved", results)
+	}
+
+	if results[1].Status != usecases.StatusInvalid || results[1].Reason != "work type Work is unknown for MAT-1, expected one of Development, Meeting" {
+		t.Fatal("Expected unknown work type to be invalid", results[1])
+	}
+
+	if len(savedTypes) != 2 {
+		t.Fatal("Invalid log should not be saved", savedTypes)
+	}
+
+	results, err = interactor.SaveWorklogs([]usecases.SaveTimeLog{{IssueId: "MAT-500", Duration: 30, Date: date, WorkType: "Meeting"}})
+
+	if _, ok := err.(usecases.WorkLogsRejected); !ok || results[0].Status != usecases.StatusRejected {
+		t.Fatal("Expected log to be rejected when work types can't be listed", results, err)
+	}
+}
+
+func TestGetRoundsDurationsByProjectPolicyAndDailyCap(t *testing.T) {
+	day := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextDay := day.AddDate(0, 0, 1)
+
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.NewIssueKeyExtractor(true, nil, nil, time.Minute),
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId, Project: domain.Project{ShortName: issueId[:3]}}, nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{
+					{Id: "card1", Title: "MAT-1", Duration: 7, Date: day},
+					{Id: "card2", Title: "MAT-2", Duration: 53, Date: day},
+					{Id: "card3", Title: "SUP-1", Duration: 7, Date: day},
+					{Id: "card4", Title: "no issue", Duration: 0, Date: day},
+					{Id: "card5", Title: "MAT-3", Duration: 400, Date: nextDay},
+					{Id: "card6", Title: "MAT-4", Duration: 100, Date: nextDay},
+					// unlinked entries are not logged, so they are not cut and don't count
+					{Id: "card7", Title: "lunch", Duration: 120, Date: nextDay},
+				}, nil
+			},
+		}},
+		Rounding: &usecases.DurationRounding{
+			Default: domain.RoundingPolicy{Mode: domain.RoundUp, Increment: 15},
+			Projects: map[string]domain.RoundingPolicy{
+				"SUP": {Mode: domain.RoundNearest, Increment: 30, Minimum: 30},
+			},
+			DailyCap: 480,
+		},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		duration int64
+		raw      int64
+		policy   string
+		capped   bool
+	}{
+		{15, 7, "up 15m", false},
+		{60, 53, "up 15m", false},
+		{30, 7, "nearest 30m, min 30m", false},
+		{0, 0, "up 15m", false},
+		{405, 400, "up 15m", false},
+		{75, 100, "up 15m", true},
+		{120, 120, "up 15m", false},
+	}
+
+	if len(items) != len(expected) {
+		t.Fatal("Unexpected items", items)
+	}
+
+	for index, item := range items {
+		rounding := item.Entry.Rounding
+
+		if rounding == nil {
+			t.Fatal("Expected rounding to be set", index)
+		}
+
+		if item.Entry.Duration != expected[index].duration || rounding.Raw != expected[index].raw || rounding.Policy != expected[index].policy || rounding.Capped != expected[index].capped {
+			t.Fatalf("Unexpected rounding of %d: %d %+v", index, item.Entry.Duration, rounding)
+		}
+	}
+}
+
+func TestGetRoundsSplitEntryAsAWhole(t *testing.T) {
+	interactor := usecases.TimeLoggerInteractor{
+		IssueIdExtractor: interfaces.NewIssueKeyExtractor(true, []string{"ABC"}, nil, time.Minute),
+		IssueRepository: &issueRepositoryMock{
+			findIssue: func(issueId string) (domain.Issue, error) {
+				return domain.Issue{Id: issueId}, nil
+			},
+		},
+		TimeSources: []usecases.TimeSource{&timeSourceMock{
+			getTimeEntries: func() ([]domain.TimeEntry, error) {
+				return []domain.TimeEntry{{Id: "card1", Title: "ABC-1 ABC-2 ABC-3", Duration: 100}}, nil
+			},
+		}},
+		Rounding: &usecases.DurationRounding{
+			Default: domain.RoundingPolicy{Mode: domain.RoundUp, Increment: 15},
+		},
+	}
+
+	items, err := interactor.GetLoggableItems(domain.TimeEntryFilter{}, domain.TimeWindow{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+
+	for index, raw := range []int64{34, 33, 33} {
+		entry := items[index].Entry
+
+		if entry.Duration%15 != 0 || entry.Rounding == nil || entry.Rounding.Raw != raw {
+			t.Fatalf("Unexpected rounding of %d: %d %+v", index, entry.Duration, entry.Rounding)
+		}
+
+		total += entry.Duration
+	}
+
+	if total != 105 {
+		t.Fatal("Expected parts to add up to rounded entry", total)
+	}
 }
@@ -0,0 +1,52 @@
+package usecases
+
+import (
+	"strings"
+
+	"github.com/vizualni/meyougotrack/domain"
+)
+
+// WorkTypeLister is an issue repository knowing which work types issues
+// accept, e.g. youtrack. Issues of other repositories accept any work type.
+type WorkTypeLister interface {
+	// WorkTypes lists work types of the project issue belongs to, any
+	// work type is accepted when project has none configured
+	WorkTypes(issueId string) ([]string, error)
+}
+
+// WorkTypes lists work types issue accepts, empty when it accepts any
+func (t *TimeLoggerInteractor) WorkTypes(issueId string) ([]string, error) {
+	if issueId == "" {
+		return nil, NewError(ErrorValidation, nil, "issue id is missing")
+	}
+
+	lister, ok := t.IssueRepository.(WorkTypeLister)
+
+	if !ok {
+		return nil, nil
+	}
+
+	return lister.WorkTypes(issueId)
+}
+
+// checkWorkType returns validation error when issue of the log doesn't
+// accept its work type, logs without work type are always accepted
+func (t *TimeLoggerInteractor) checkWorkType(log domain.IssueWorkLog) error {
+	if log.Type == "" {
+		return nil
+	}
+
+	workTypes, err := t.WorkTypes(log.IssueId)
+
+	if err != nil || len(workTypes) == 0 {
+		return err
+	}
+
+	for _, workType := range workTypes {
+		if strings.EqualFold(workType, log.Type) {
+			return nil
+		}
+	}
+
+	return NewError(ErrorValidation, nil, "work type %s is unknown for %s, expected one of %s", log.Type, log.IssueId, strings.Join(workTypes, ", "))
+}